@@ -0,0 +1,745 @@
+// Package cli is the go-kafka-console-consumer command line,
+// programs linking in their own decoders call Run from main
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/signal"
+	"plugin"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Shopify/sarama"
+	cluster "github.com/bsm/sarama-cluster"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/consumer"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/converters"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/output"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultConfigPath = "etc/config.yaml"
+	// execPrefix marks a -type that runs a decoder process
+	execPrefix = "exec:"
+	// saslPasswordEnv holds the SASL password when
+	// -sasl-password-file isn't passed
+	saslPasswordEnv = "KAFKA_SASL_PASSWORD"
+)
+
+var (
+	log            = logrus.New()
+	errNoBrokers   = errors.New("at least one broker URL is required")
+	errNoTopic     = errors.New("a topic is required")
+	errNoType      = errors.New("a message type or path to type plugin is required")
+	errNoSchemas   = errors.New("a schema is required for message type Avro")
+	errNoKeySchema = errors.New("a key schema is required for key type Avro")
+	errNoRegistry  = errors.New("a schema registry URL is required for type avro-registry")
+	errNoProtoMsg  = errors.New("a protobuf message name is required for type protobuf")
+	errGroupOffset = errors.New("a group cannot be combined with partition, offset or from-time")
+	errOffsetTime  = errors.New("offset and from-time cannot be combined")
+	errNoExecCmd   = errors.New("a command is required after " + execPrefix)
+	errNoOutFile   = errors.New("rotate-size, rotate-interval and split-by require an output-file")
+	errNoSASLUser  = errors.New("sasl-password-file requires a sasl-user")
+)
+
+// decoderOptions holds the flags some of
+// the built-in decoders need
+type decoderOptions struct {
+	registryURL     string
+	registryTimeout time.Duration
+	protoMessage    string
+	avro            decoders.AvroOptions
+	writerSchema    string
+}
+
+// listFlag collects every value of a repeated flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Run parses args, the command line arguments without the
+// program name, and consumes until interrupted or the limits
+// are reached. -type accepts any name in registry. The
+// returned code is meant to be passed to os.Exit.
+func Run(args []string, registry *decoders.Registry) int {
+	flags := flag.NewFlagSet("go-kafka-console-consumer", flag.ContinueOnError)
+
+	// Read config from command line
+	brokers := flags.String("bootstrap-server", "", "Comma separated Kafka Broker URLs")
+	topic := flags.String("topic", "", "Topic name")
+	groupID := flags.String("group", "", "Optional, pass the Kafka GroupId")
+	fromBeginning := flags.Bool("from-beginning", false, "Optional, if passed the program will start at the earliest offset")
+	msgType := flags.String("type", "",
+		fmt.Sprintf("Pass the supported type name here, the path to your plugin, or exec:/path/to/decoder to run a decoder process. Supported types are %s", strings.Join(registry.Names(), ", ")))
+	schemas := flags.String("schemas", "", "If the message type uses schemas, pass them here.")
+	writerSchema := flags.String("writer-schema", "", "Optional, the .avsc file or directory of .avsc files avro messages were written with. -schemas is then the reader schema every message is resolved to")
+	registryURL := flags.String("schema-registry-url", "", "Schema registry URL used by the avro-registry type")
+	registryTimeout := flags.Duration("schema-registry-timeout", decoders.DefaultRegistryTimeout, "Optional, how long a request to the schema registry may take before the message fails to decode")
+	avroDecimalString := flags.Bool("avro-decimal-string", false, "Optional, print Avro decimals as exact strings instead of bytes")
+	avroTimeLayout := flags.String("avro-time-layout", "", "Optional, print Avro timestamps with this Go time layout, e.g. 2006-01-02T15:04:05.000Z07:00. Dates and times of day are printed too")
+	avroTimeZone := flags.String("avro-time-zone", "UTC", "Optional, time zone Avro timestamps are printed in, e.g. Local or America/New_York")
+	avroUnwrapUnions := flags.Bool("avro-unwrap-unions", false, "Optional, print the value of Avro unions without the {\"type\": value} wrapper")
+	protoMessage := flags.String("proto-message", "", "Fully qualified message name used by the protobuf type, e.g. example.v1.User")
+	keyProtoMessage := flags.String("key-proto-message", "", "Fully qualified message name used by the protobuf key type")
+	keyType := flags.String("key-type", "", "Optional, decode message keys with this type, takes the same values as -type. Keys are printed as strings by default")
+	keySchemas := flags.String("key-schemas", "", "If the key type uses schemas, pass them here.")
+	var converterSpecs listFlag
+	flags.Var(&converterSpecs, "converter",
+		fmt.Sprintf("Optional and repeatable, converts fields of decoded messages in order. Pass the path to a converter plugin or name:field,other.field where name is one of %s", strings.Join(converters.Names, ", ")))
+	partition := flags.Int("partition", -1, "Optional, consume only this partition without joining a group")
+	offset := flags.String("offset", "", "Optional, start at this offset without joining a group. Pass an absolute offset, oldest, newest or a negative value relative to newest")
+	outputFormat := flags.String("output", "json",
+		fmt.Sprintf("Optional, pass the output format or the path to your formatter plugin. Out of the box supported formats are %s", strings.Join(output.SupportedFormats, ", ")))
+	fields := flags.String("fields", "", "Comma separated field paths used as columns by the csv output, e.g. partition,offset,value.user.id")
+	outputTemplate := flags.String("template", "", "Go text/template used by the template output, e.g. {{.Partition}}:{{.Offset}} {{.Value.user.id}}")
+	outputFile := flags.String("output-file", "", "Optional, write messages to this file instead of stdout, an existing file is appended to")
+	rotateSize := flags.String("rotate-size", "", "Optional, start a new -output-file once it reaches this size, e.g. 100MB")
+	rotateInterval := flags.Duration("rotate-interval", 0, "Optional, start a new -output-file after this long, e.g. 15m")
+	splitBy := flags.String("split-by", "", "Optional, write one -output-file per partition or per value of a field, e.g. partition or value.user.id")
+	maxMessages := flags.Int("max-messages", 0, "Optional, exit after printing this many messages")
+	exitAtEnd := flags.Bool("exit-at-end", false, "Optional, exit once every partition has been read up to its end at startup")
+	untilOffset := flags.Int64("until-offset", -1, "Optional, exit once every partition has been read up to and including this offset")
+	untilTime := flags.String("until-time", "", "Optional, exit once every partition has been read up to this time. Pass an RFC3339 time or a duration such as 2h")
+	onError := flags.String("on-error", string(parser.ErrorPolicySkip), "Optional, what to do with messages that can't be decoded: skip them, stop with a non-zero exit code or emit them with their raw value and the error")
+	deadLetterFile := flags.String("dead-letter-file", "", "Optional, append messages that can't be decoded to this file as newline delimited JSON")
+	summary := flags.Bool("summary", false, "Optional, print how many messages were read from each partition, how many couldn't be decoded, their size and the elapsed time to stderr on exit")
+	hideTombstones := flags.Bool("hide-tombstones", false, "Optional, skip records with a null value, deletes on compacted topics, instead of printing them with \"tombstone\": true")
+	useTLS := flags.Bool("tls", false, "Optional, connect to brokers over TLS. Implied by the other -tls flags")
+	tlsCAFile := flags.String("tls-ca-file", "", "Optional, PEM bundle of the CAs trusted to sign broker certificates, the system roots are used by default")
+	tlsCertFile := flags.String("tls-cert-file", "", "Optional, PEM client certificate for mutual TLS, requires -tls-key-file")
+	tlsKeyFile := flags.String("tls-key-file", "", "Optional, PEM key of the -tls-cert-file")
+	tlsServerName := flags.String("tls-server-name", "", "Optional, name checked against broker certificates instead of the broker host")
+	tlsInsecureSkipVerify := flags.Bool("tls-insecure-skip-verify", false, "Optional, accept any broker certificate. Only use this for testing")
+	saslUser := flags.String("sasl-user", "", "Optional, authenticate with SASL/PLAIN as this user. The password is read from -sasl-password-file or the "+saslPasswordEnv+" environment variable")
+	saslPasswordFile := flags.String("sasl-password-file", "", "Optional, file holding the SASL/PLAIN password")
+	fromTime := flags.String("from-time", "", "Optional, start at the first message at or after this time without joining a group. Pass an RFC3339 time or a duration such as 2h")
+
+	err := flags.Parse(args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	err = checkArgs(brokers, topic, groupID, msgType, schemas)
+	if err != nil {
+		log.Errorf("Could not validate args: %s", err.Error())
+		return 1
+	}
+
+	if strings.EqualFold(*keyType, "avro") && *keySchemas == "" {
+		log.Errorf("Could not validate args: %s", errNoKeySchema.Error())
+		return 1
+	}
+
+	if (*msgType == "avro-registry" || *keyType == "avro-registry") && *registryURL == "" {
+		log.Errorf("Could not validate args: %s", errNoRegistry.Error())
+		return 1
+	}
+
+	if (*msgType == "protobuf" && *protoMessage == "") || (*keyType == "protobuf" && *keyProtoMessage == "") {
+		log.Errorf("Could not validate args: %s", errNoProtoMsg.Error())
+		return 1
+	}
+
+	errorPolicy, err := getErrorPolicy(*onError)
+	if err != nil {
+		log.Errorf("Could not validate args: %s", err.Error())
+		return 1
+	}
+
+	sink, err := getSink(*outputFile, *rotateSize, *rotateInterval, *splitBy)
+	if err != nil {
+		log.Errorf("Could not validate args: %s", err.Error())
+		return 1
+	}
+
+	avroOptions := decoders.AvroOptions{
+		DecimalAsString: *avroDecimalString,
+		TimeLayout:      *avroTimeLayout,
+		UnwrapUnions:    *avroUnwrapUnions,
+	}
+	avroOptions.Location, err = time.LoadLocation(*avroTimeZone)
+	if err != nil {
+		log.Errorf("Could not validate args: %s", err.Error())
+		return 1
+	}
+
+	security := consumer.Security{
+		TLS:                *useTLS,
+		CAFile:             *tlsCAFile,
+		CertFile:           *tlsCertFile,
+		KeyFile:            *tlsKeyFile,
+		ServerName:         *tlsServerName,
+		InsecureSkipVerify: *tlsInsecureSkipVerify,
+		SASLUser:           *saslUser,
+	}
+	security.SASLPassword, err = getSASLPassword(*saslUser, *saslPasswordFile)
+	if err != nil {
+		log.Errorf("Could not validate args: %s", err.Error())
+		return 1
+	}
+
+	config, err := newConfig(*fromBeginning, security)
+	if err != nil {
+		log.Errorf("Could not validate args: %s", err.Error())
+		return 1
+	}
+
+	brokersSlice := strings.Split(*brokers, ",")
+
+	until := consumer.Until{
+		AtEnd:  *exitAtEnd,
+		Offset: *untilOffset,
+	}
+	if *untilTime != "" {
+		until.Time, err = consumer.ParseTime(*untilTime, time.Now())
+		if err != nil {
+			log.Errorf("Could not validate args: %s", err.Error())
+			return 1
+		}
+	}
+
+	// Explicit partitions or offsets are read without a group
+	fixedOffsets := *partition >= 0 || *offset != "" || *fromTime != ""
+	var start consumer.Offset
+	if fixedOffsets {
+		if *groupID != "" {
+			log.Errorf("Could not validate args: %s", errGroupOffset.Error())
+			return 1
+		}
+
+		start, err = startOffset(*offset, *fromTime, *fromBeginning)
+		if err != nil {
+			log.Errorf("Could not validate args: %s", err.Error())
+			return 1
+		}
+	}
+
+	// Decoders, converters and the formatter are loaded and
+	// schemas validated before connecting, so mistakes in
+	// them show up right away
+	decoder, err := getDecoder(registry, *msgType, decoderOptions{
+		registryURL:     *registryURL,
+		registryTimeout: *registryTimeout,
+		protoMessage:    *protoMessage,
+		avro:            avroOptions,
+		writerSchema:    *writerSchema,
+	})
+	if err != nil {
+		log.Errorf("Could not load decoder: %s", err.Error())
+		return 1
+	}
+	// Stop any decoder processes
+	defer closeDecoders(decoder)
+	if err := decoder.ValidateSchemas(*schemas); err != nil {
+		log.Errorf("Could not validate schemas: %s", err.Error())
+		return 1
+	}
+
+	var keyDecoder parser.RecordDecoder
+	if *keyType != "" {
+		keyDecoder, err = getDecoder(registry, *keyType, decoderOptions{
+			registryURL:     *registryURL,
+			registryTimeout: *registryTimeout,
+			protoMessage:    *keyProtoMessage,
+			avro:            avroOptions,
+		})
+		if err != nil {
+			log.Errorf("Could not load key decoder: %s", err.Error())
+			return 1
+		}
+		defer closeDecoders(keyDecoder)
+		if err := keyDecoder.ValidateSchemas(*keySchemas); err != nil {
+			log.Errorf("Could not validate key schemas: %s", err.Error())
+			return 1
+		}
+	}
+
+	valueConverters, err := getConverters(converterSpecs)
+	if err != nil {
+		log.Errorf("Could not load converters: %s", err.Error())
+		return 1
+	}
+
+	formatter, err := getFormatter(*outputFormat, output.Options{
+		Fields:   splitList(*fields),
+		Template: *outputTemplate,
+	})
+	if err != nil {
+		log.Errorf("Could not validate args: %s", err.Error())
+		return 1
+	}
+
+	var deadLetters *os.File
+	if *deadLetterFile != "" {
+		deadLetters, err = os.OpenFile(*deadLetterFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			log.Errorf("Could not open dead letter file: %s", err.Error())
+			return 1
+		}
+		defer deadLetters.Close()
+	}
+
+	// Create a new client, blocks until connection to brokers established
+	client := newClient(brokersSlice, config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			log.Errorf("Error closing client: %s", err.Error())
+		}
+	}()
+
+	// Offsets each partition starts at, only needed
+	// to work out which partitions have anything to read
+	var starts map[int32]int64
+	var kafkaConsumer parser.Consumer
+	if fixedOffsets {
+		var partitions []int32
+		if *partition >= 0 {
+			partitions = []int32{int32(*partition)}
+		}
+
+		starts, err = consumer.ResolveOffsets(client, *topic, partitions, start)
+		if err != nil {
+			log.Errorf("Unable to start consumer: %s", err.Error())
+			return 1
+		}
+
+		partitionConsumer, err := consumer.NewPartitionConsumer(client, *topic, starts)
+		if err != nil {
+			log.Errorf("Unable to start consumer: %s", err.Error())
+			return 1
+		}
+		kafkaConsumer = partitionConsumer
+	} else {
+		if *groupID == "" {
+			*groupID = uuid.NewV4().String()
+		}
+
+		if until.Bounded() {
+			starts, err = consumer.GroupOffsets(client, *groupID, *topic)
+			if err != nil {
+				log.Errorf("Unable to start consumer: %s", err.Error())
+				return 1
+			}
+		}
+
+		groupConsumer, err := newConsumer(client, *topic, *groupID)
+		if err != nil {
+			log.Errorf("Unable to start consumer: %s", err.Error())
+			return 1
+		}
+		kafkaConsumer = groupConsumer
+	}
+	// Closing again once the parser has closed it does nothing
+	defer kafkaConsumer.Close()
+
+	limits := parser.Limits{
+		MaxMessages: *maxMessages,
+		Until:       until.Time,
+	}
+	if until.Bounded() {
+		limits.EndOffsets, err = consumer.EndOffsets(client, *topic, starts, until)
+		if err != nil {
+			log.Errorf("Unable to start consumer: %s", err.Error())
+			return 1
+		}
+	}
+
+	opts := []parser.Option{
+		parser.WithLimits(limits),
+		parser.WithFormatter(formatter),
+		parser.WithConverters(valueConverters...),
+		parser.WithTombstones(!*hideTombstones),
+		parser.WithErrorPolicy(errorPolicy),
+	}
+	if *summary {
+		opts = append(opts, parser.WithSummary(os.Stderr))
+	}
+	if sink != nil {
+		opts = append(opts, parser.WithSink(sink))
+	}
+	if keyDecoder != nil {
+		opts = append(opts, parser.WithKeyRecordDecoder(validatedDecoder{keyDecoder}, *keySchemas))
+	}
+	if deadLetters != nil {
+		opts = append(opts, parser.WithDeadLetters(deadLetters))
+	}
+
+	parser, err := parser.NewWithRecordDecoder(kafkaConsumer, *topic, *schemas, validatedDecoder{decoder}, log, opts...)
+	if err != nil {
+		log.Errorf("Could not initialize parser: %s", err.Error())
+		return 1
+	}
+	// Keep program running until the user
+	// triggers a shutdown or the limits are reached
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, os.Kill, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Run lets the message being printed finish, Close
+	// then commits offsets and leaves the group
+	runErr := parser.Run(ctx)
+	if err := parser.Close(); err != nil {
+		log.Errorf("Error stopping consumer: %s", err.Error())
+	}
+
+	if runErr != nil && runErr != context.Canceled {
+		log.Errorf("Stopped: %s", runErr.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// getErrorPolicy checks -on-error is one of parser.ErrorPolicies
+func getErrorPolicy(name string) (parser.ErrorPolicy, error) {
+	names := make([]string, len(parser.ErrorPolicies))
+	for i, policy := range parser.ErrorPolicies {
+		if string(policy) == name {
+			return policy, nil
+		}
+		names[i] = string(policy)
+	}
+
+	return "", errors.Errorf("unknown error policy %s, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// validatedDecoder is a decoder whose schemas were
+// validated before connecting, the parser doesn't
+// validate them again
+type validatedDecoder struct {
+	parser.RecordDecoder
+}
+
+func (validatedDecoder) ValidateSchemas(schemas string) error {
+	return nil
+}
+
+// closeDecoders stops the decoders that run
+// a process, nil decoders are skipped
+func closeDecoders(recordDecoders ...parser.RecordDecoder) {
+	for _, decoder := range recordDecoders {
+		if closer, ok := decoder.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Errorf("Error stopping decoder: %s", err.Error())
+			}
+		}
+	}
+}
+
+func checkArgs(brokers, topic, groupID, msgType, schemas *string) error {
+	if *brokers == "" {
+		return errNoBrokers
+	}
+
+	if *topic == "" {
+		return errNoTopic
+	}
+
+	if *msgType == "" {
+		return errNoType
+	}
+
+	if strings.EqualFold(*msgType, "avro") && *schemas == "" {
+		return errNoSchemas
+	}
+
+	return nil
+}
+
+// getDecoder builds the decoder for msgType, which is either
+// a registered name, exec: followed by a command, or the path
+// to a decoder plugin
+func getDecoder(registry *decoders.Registry, msgType string, opts decoderOptions) (parser.RecordDecoder, error) {
+	if factory, ok := registry.Lookup(msgType); ok {
+		return factory(decoders.Options{
+			Log:             log,
+			RegistryURL:     opts.registryURL,
+			RegistryTimeout: opts.registryTimeout,
+			ProtoMessage:    opts.protoMessage,
+			Avro:            opts.avro,
+			WriterSchemas:   opts.writerSchema,
+		})
+	}
+
+	if strings.HasPrefix(msgType, execPrefix) {
+		command, err := decoders.SplitCommand(strings.TrimPrefix(msgType, execPrefix))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading %s decoder", msgType)
+		}
+		if len(command) == 0 {
+			return nil, errNoExecCmd
+		}
+		return &decoders.ExecDecoder{
+			Command: command[0],
+			Args:    command[1:],
+		}, nil
+	}
+
+	// Open the plugin
+	plug, err := plugin.Open(msgType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error linking %s decoder", msgType)
+	}
+
+	// Look for exported Decoder
+	symDecoder, err := plug.Lookup("Decoder")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading %s Decoder", msgType)
+	}
+
+	// Plugins implementing parser.RecordDecoder are
+	// passed the whole record, anything else must be
+	// a parser.Decoder which only sees the value
+	if decoder, ok := symDecoder.(parser.RecordDecoder); ok {
+		return decoder, nil
+	}
+
+	// don't use 'ok' to check the assertion,
+	// the message displayed by the panic is
+	// more useful than a prettier error message here
+	return parser.FromDecoder(symDecoder.(parser.Decoder)), nil
+}
+
+// getConverters creates the built-in converters and
+// loads converter plugins in the order they were passed
+func getConverters(specs []string) ([]parser.Converter, error) {
+	var loaded []parser.Converter
+	for _, spec := range specs {
+		if converters.IsBuiltin(spec) {
+			converter, err := converters.New(spec)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error creating %s converter", spec)
+			}
+			loaded = append(loaded, converter)
+			continue
+		}
+
+		cplug, err := plugin.Open(spec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error linking %s converter", spec)
+		}
+
+		symConverter, err := cplug.Lookup("Converter")
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading %s converter", spec)
+		}
+
+		loaded = append(loaded, symConverter.(parser.Converter))
+	}
+
+	return loaded, nil
+}
+
+// getSink returns the sink writing to outputFile,
+// nil when messages are written to stdout
+func getSink(outputFile, rotateSize string, rotateInterval time.Duration, splitBy string) (parser.Sink, error) {
+	if outputFile == "" {
+		if rotateSize != "" || rotateInterval != 0 || splitBy != "" {
+			return nil, errNoOutFile
+		}
+		return nil, nil
+	}
+
+	maxBytes, err := parseSize(rotateSize)
+	if err != nil {
+		return nil, err
+	}
+	rotation := output.Rotation{
+		MaxBytes: maxBytes,
+		Interval: rotateInterval,
+	}
+
+	if splitBy != "" {
+		return output.NewSplitSink(outputFile, splitBy, rotation), nil
+	}
+
+	return output.NewFileSink(outputFile, rotation), nil
+}
+
+// parseSize reads a number of bytes with an optional
+// KB, MB or GB suffix, 0 if s is empty
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	number, unit := strings.ToUpper(s), int64(1)
+	for i, suffix := range []string{"KB", "MB", "GB"} {
+		if strings.HasSuffix(number, suffix) || strings.HasSuffix(number, suffix[:1]) {
+			number = strings.TrimSuffix(strings.TrimSuffix(number, suffix), suffix[:1])
+			unit = 1 << (10 * uint(i+1))
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.Errorf("invalid size %s, pass a positive number of bytes optionally followed by KB, MB or GB", s)
+	}
+
+	return n * unit, nil
+}
+
+func getFormatter(format string, opts output.Options) (parser.Formatter, error) {
+	if output.IsSupported(format) {
+		return output.New(format, opts)
+	}
+
+	// Open the plugin
+	plug, err := plugin.Open(format)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error linking %s formatter", format)
+	}
+
+	// Look for exported Formatter
+	symFormatter, err := plug.Lookup("Formatter")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading %s Formatter", format)
+	}
+
+	// Same as decoders, the panic is more
+	// useful than a prettier error message
+	return symFormatter.(parser.Formatter), nil
+}
+
+// splitList splits a comma separated flag,
+// an empty flag gives an empty list
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+
+	return strings.Split(list, ",")
+}
+
+// getSASLPassword reads the SASL/PLAIN password from
+// passwordFile, or the environment if it isn't passed.
+// Passwords are never taken from a flag so they
+// don't show up in the process list.
+func getSASLPassword(user, passwordFile string) (string, error) {
+	if user == "" {
+		if passwordFile != "" {
+			return "", errNoSASLUser
+		}
+		return "", nil
+	}
+
+	if passwordFile == "" {
+		return os.Getenv(saslPasswordEnv), nil
+	}
+
+	password, err := ioutil.ReadFile(passwordFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading SASL password file %s", passwordFile)
+	}
+
+	return strings.TrimRight(string(password), "\r\n"), nil
+}
+
+// newConfig returns the Sarama cluster config,
+// used with and without a group
+func newConfig(fromBeginning bool, security consumer.Security) (*cluster.Config, error) {
+	config := cluster.NewConfig()
+	config.Consumer.Return.Errors = true
+	config.Group.Return.Notifications = true
+	config.Version = sarama.V0_11_0_0
+
+	if fromBeginning {
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
+
+	if err := security.Apply(&config.Config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func newClient(brokers []string, config *cluster.Config) *cluster.Client {
+	var counter = 1.
+	var client *cluster.Client
+	var err error
+
+	// Attempt to connect to brokers forever w/ exponential backoff
+	for {
+		client, err = cluster.NewClient(brokers, config)
+		if err == nil {
+			break
+		}
+
+		backoff := 100 * time.Millisecond * time.Duration(math.Pow(2, counter))
+		counter++
+		log.Errorf("Unable to start consumer: %s", err.Error())
+		log.Errorf("Backing off for %d ms...", backoff/time.Millisecond)
+		time.Sleep(backoff)
+	}
+
+	return client
+}
+
+func newConsumer(client *cluster.Client, topic string, groupID string) (*cluster.Consumer, error) {
+	// Sarama cluster accepts multiple topics,
+	// this doesn't.
+	topics := []string{
+		topic,
+	}
+
+	return cluster.NewConsumerFromClient(client, groupID, topics)
+}
+
+// startOffset picks where a group-free consumer starts
+// based on the offset, from-time and from-beginning flags
+func startOffset(offset, fromTime string, fromBeginning bool) (consumer.Offset, error) {
+	if fromTime != "" {
+		if offset != "" {
+			return consumer.Offset{}, errOffsetTime
+		}
+
+		t, err := consumer.ParseTime(fromTime, time.Now())
+		if err != nil {
+			return consumer.Offset{}, err
+		}
+
+		return consumer.TimeOffset(t), nil
+	}
+
+	if offset == "" {
+		offset = "newest"
+		if fromBeginning {
+			offset = "oldest"
+		}
+	}
+
+	return consumer.ParseOffset(offset)
+}
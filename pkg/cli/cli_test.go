@@ -0,0 +1,51 @@
+package cli_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/cli"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHelp(t *testing.T) {
+	assert.Equal(t, 0, cli.Run([]string{"-h"}, decoders.NewDefaultRegistry()))
+}
+
+func TestRunUnknownFlag(t *testing.T) {
+	assert.Equal(t, 2, cli.Run([]string{"-not-a-flag"}, decoders.NewDefaultRegistry()))
+}
+
+func TestRunInvalidArgs(t *testing.T) {
+	assert.Equal(t, 1, cli.Run([]string{"-topic", "test"}, decoders.NewDefaultRegistry()))
+}
+
+func TestRunUnknownErrorPolicy(t *testing.T) {
+	assert.Equal(t, 1, cli.Run([]string{"-bootstrap-server", "localhost:9092", "-topic", "test", "-type", "json", "-on-error", "retry"}, decoders.NewDefaultRegistry()))
+}
+
+func TestRunInvalidOutputFile(t *testing.T) {
+	args := []string{"-bootstrap-server", "localhost:9092", "-topic", "test", "-type", "json"}
+	assert.Equal(t, 1, cli.Run(append(args, "-split-by", "partition"), decoders.NewDefaultRegistry()))
+	assert.Equal(t, 1, cli.Run(append(args, "-output-file", "out.ndjson", "-rotate-size", "lots"), decoders.NewDefaultRegistry()))
+}
+
+func TestRunInvalidSecurity(t *testing.T) {
+	os.Unsetenv("KAFKA_SASL_PASSWORD")
+
+	args := []string{"-bootstrap-server", "localhost:9092", "-topic", "test", "-type", "json"}
+	assert.Equal(t, 1, cli.Run(append(args, "-tls-cert-file", "client.pem"), decoders.NewDefaultRegistry()))
+	assert.Equal(t, 1, cli.Run(append(args, "-tls-ca-file", "missing.pem"), decoders.NewDefaultRegistry()))
+	assert.Equal(t, 1, cli.Run(append(args, "-sasl-user", "user"), decoders.NewDefaultRegistry()))
+	assert.Equal(t, 1, cli.Run(append(args, "-sasl-password-file", "password"), decoders.NewDefaultRegistry()))
+}
+
+func TestRunInvalidDecoderBeforeConnecting(t *testing.T) {
+	// Nothing listens on port 1, connecting would retry forever
+	args := []string{"-bootstrap-server", "localhost:1", "-topic", "test"}
+	assert.Equal(t, 1, cli.Run(append(args, "-type", "avro", "-schemas", "missing.avsc"), decoders.NewDefaultRegistry()))
+	assert.Equal(t, 1, cli.Run(append(args, "-type", "exec:'/opt/my plugins/dec"), decoders.NewDefaultRegistry()))
+	assert.Equal(t, 1, cli.Run(append(args, "-type", "json", "-key-type", "avro", "-key-schemas", "missing.avsc"), decoders.NewDefaultRegistry()))
+	assert.Equal(t, 1, cli.Run(append(args, "-type", "json", "-output", "template", "-template", "{{.Offset"), decoders.NewDefaultRegistry()))
+}
@@ -0,0 +1,104 @@
+package consumer
+
+import (
+	"math"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// Until describes where reading a topic stops. The zero value,
+// apart from Offset which uses -1 to mean unset, reads forever.
+type Until struct {
+	// AtEnd stops at the high water mark taken at startup
+	AtEnd bool
+	// Offset is the last offset read from every partition
+	Offset int64
+	// Time stops before the first message written at or after it
+	Time time.Time
+}
+
+// Bounded reports whether any stop condition is set
+func (u Until) Bounded() bool {
+	return u.AtEnd || u.Offset >= 0 || !u.Time.IsZero()
+}
+
+// EndOffsets returns the exclusive offset at which each partition
+// in starts is complete. Partitions which have nothing to read
+// between their start and end are left out of the result. A partition
+// which is only bounded by a time that hasn't been reached yet gets
+// math.MaxInt64, its end is found by message timestamp instead.
+func EndOffsets(client sarama.Client, topic string, starts map[int32]int64, until Until) (map[int32]int64, error) {
+	ends := make(map[int32]int64, len(starts))
+	now := time.Now()
+
+	for partition, start := range starts {
+		end := int64(math.MaxInt64)
+
+		if until.AtEnd {
+			newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not get high water mark for partition %d", partition)
+			}
+			end = newest
+		}
+
+		if until.Offset >= 0 && until.Offset+1 < end {
+			end = until.Offset + 1
+		}
+
+		// A time in the future can't be resolved to an
+		// offset yet, it is checked against each message
+		if !until.Time.IsZero() && until.Time.Before(now) {
+			offset, err := offsetForTime(client, topic, partition, until.Time)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not resolve end time for partition %d", partition)
+			}
+
+			if offset < end {
+				end = offset
+			}
+		}
+
+		if end > start {
+			ends[partition] = end
+		}
+	}
+
+	return ends, nil
+}
+
+// GroupOffsets returns the offset the consumer group will resume
+// from on every partition of the topic, falling back to the
+// client's Consumer.Offsets.Initial for partitions it hasn't committed
+func GroupOffsets(client sarama.Client, group, topic string) (map[int32]int64, error) {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	manager, err := sarama.NewOffsetManagerFromClient(group, client)
+	if err != nil {
+		return nil, err
+	}
+	defer manager.Close()
+
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		partitionManager, err := manager.ManagePartition(topic, partition)
+		if err != nil {
+			return nil, err
+		}
+		next, _ := partitionManager.NextOffset()
+		partitionManager.Close()
+
+		offset, err := Offset{value: next}.Resolve(client, topic, partition)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not resolve offset for partition %d", partition)
+		}
+		offsets[partition] = offset
+	}
+
+	return offsets, nil
+}
@@ -0,0 +1,45 @@
+package consumer_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/consumer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndOffsets(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	broker, client := newTestBroker(t, map[int64]int64{
+		sarama.OffsetNewest:                       10,
+		past.UnixNano() / int64(time.Millisecond): 6,
+	}, nil)
+	defer broker.Close()
+	defer client.Close()
+
+	tests := []struct {
+		name     string
+		start    int64
+		until    consumer.Until
+		expected map[int32]int64
+	}{
+		{"at end", 5, consumer.Until{AtEnd: true, Offset: -1}, map[int32]int64{0: 10}},
+		{"until offset", 5, consumer.Until{Offset: 7}, map[int32]int64{0: 8}},
+		{"earliest wins", 5, consumer.Until{AtEnd: true, Offset: 12}, map[int32]int64{0: 10}},
+		{"until past time", 5, consumer.Until{Offset: -1, Time: past}, map[int32]int64{0: 6}},
+		{"until future time", 5, consumer.Until{Offset: -1, Time: future}, map[int32]int64{0: math.MaxInt64}},
+		{"nothing to read", 10, consumer.Until{AtEnd: true, Offset: -1}, map[int32]int64{}},
+	}
+
+	for _, test := range tests {
+		require.True(t, test.until.Bounded(), test.name)
+
+		ends, err := consumer.EndOffsets(client, testTopic, map[int32]int64{0: test.start}, test.until)
+		require.Nil(t, err, test.name)
+		assert.Equal(t, test.expected, ends, test.name)
+	}
+}
@@ -0,0 +1,159 @@
+package consumer
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// Offset is a starting position parsed from the command line.
+// It is either absolute, relative to the newest offset of a
+// partition, a point in time, or one of sarama.OffsetOldest
+// and sarama.OffsetNewest.
+type Offset struct {
+	value    int64
+	relative bool
+	time     time.Time
+}
+
+// ParseOffset accepts an absolute offset, "oldest", "newest" or
+// a negative value which is interpreted relative to the newest
+// offset, e.g. -10 starts ten messages before the end of the partition
+func ParseOffset(s string) (Offset, error) {
+	switch strings.ToLower(s) {
+	case "oldest":
+		return Offset{value: sarama.OffsetOldest}, nil
+	case "newest":
+		return Offset{value: sarama.OffsetNewest}, nil
+	}
+
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return Offset{}, errors.Errorf("invalid offset %q, must be a number, oldest or newest", s)
+	}
+
+	if value < 0 {
+		return Offset{value: value, relative: true}, nil
+	}
+
+	return Offset{value: value}, nil
+}
+
+// ParseTime accepts an RFC3339 timestamp or a duration such
+// as 2h which is subtracted from now
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time %q, must be RFC3339 or a duration", s)
+	}
+
+	// 2h and -2h both mean two hours ago
+	if d < 0 {
+		d = -d
+	}
+
+	return now.Add(-d), nil
+}
+
+// TimeOffset returns an Offset that starts at the first
+// message with a timestamp at or after t
+func TimeOffset(t time.Time) Offset {
+	return Offset{time: t}
+}
+
+// Resolve turns the offset into an absolute offset
+// for the given partition
+func (o Offset) Resolve(client sarama.Client, topic string, partition int32) (int64, error) {
+	if !o.time.IsZero() {
+		return offsetForTime(client, topic, partition, o.time)
+	}
+
+	if !o.relative && o.value >= 0 {
+		return o.value, nil
+	}
+
+	oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, err
+	}
+
+	newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case o.value == sarama.OffsetOldest && !o.relative:
+		return oldest, nil
+	case o.value == sarama.OffsetNewest && !o.relative:
+		return newest, nil
+	}
+
+	// Relative offsets never go past the
+	// beginning of the partition
+	offset := newest + o.value
+	if offset < oldest {
+		offset = oldest
+	}
+
+	return offset, nil
+}
+
+// ResolveOffsets resolves the offset for each of the
+// given partitions. If partitions is empty every partition
+// of the topic is used.
+func ResolveOffsets(client sarama.Client, topic string, partitions []int32, offset Offset) (map[int32]int64, error) {
+	if len(partitions) == 0 {
+		var err error
+		partitions, err = client.Partitions(topic)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		resolved, err := offset.Resolve(client, topic, partition)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not resolve offset for partition %d", partition)
+		}
+		offsets[partition] = resolved
+	}
+
+	return offsets, nil
+}
+
+// offsetForTime uses ListOffsets to find the earliest offset
+// whose timestamp is at or after t. When there is no such message
+// yet the newest offset is returned, so only new messages are read.
+func offsetForTime(client sarama.Client, topic string, partition int32, t time.Time) (int64, error) {
+	millis := t.UnixNano() / int64(time.Millisecond)
+	offset, err := client.GetOffset(topic, partition, millis)
+	if err != nil {
+		return 0, err
+	}
+
+	if offset < 0 {
+		return client.GetOffset(topic, partition, sarama.OffsetNewest)
+	}
+
+	return offset, nil
+}
+
+func sortedPartitions(offsets map[int32]int64) []int32 {
+	partitions := make([]int32, 0, len(offsets))
+	for partition := range offsets {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	return partitions
+}
@@ -0,0 +1,115 @@
+package consumer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/consumer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTopic = "test"
+)
+
+// newTestBroker starts a mock broker leading partition 0 of testTopic,
+// fetch may be nil if the test doesn't consume messages
+// offsets maps ListOffsets timestamps, including sarama.OffsetOldest
+// and sarama.OffsetNewest, to the offset the broker answers with
+func newTestBroker(t *testing.T, offsets map[int64]int64, fetch *sarama.MockFetchResponse) (*sarama.MockBroker, sarama.Client) {
+	broker := sarama.NewMockBroker(t, 1)
+	offsetResponse := sarama.NewMockOffsetResponse(t)
+	for timestamp, offset := range offsets {
+		offsetResponse.SetOffset(testTopic, 0, timestamp, offset)
+	}
+	handlers := map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(testTopic, 0, broker.BrokerID()),
+		"OffsetRequest": offsetResponse,
+	}
+	if fetch != nil {
+		handlers["FetchRequest"] = fetch
+	}
+	broker.SetHandlerByMap(handlers)
+
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	require.Nil(t, err)
+
+	return broker, client
+}
+
+func TestParseOffsetInvalid(t *testing.T) {
+	_, err := consumer.ParseOffset("yesterday")
+
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid offset \"yesterday\", must be a number, oldest or newest", err.Error())
+}
+
+func TestResolveOffsets(t *testing.T) {
+	broker, client := newTestBroker(t, map[int64]int64{
+		sarama.OffsetOldest: 10,
+		sarama.OffsetNewest: 100,
+	}, nil)
+	defer broker.Close()
+	defer client.Close()
+
+	tests := map[string]int64{
+		"oldest": 10,
+		"newest": 100,
+		"OLDEST": 10,
+		"42":     42,
+		"-5":     95,
+		"-500":   10,
+	}
+
+	for input, expected := range tests {
+		offset, err := consumer.ParseOffset(input)
+		require.Nil(t, err)
+
+		offsets, err := consumer.ResolveOffsets(client, testTopic, nil, offset)
+		require.Nil(t, err, input)
+		assert.Equal(t, map[int32]int64{0: expected}, offsets, input)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2018, 7, 1, 14, 5, 0, 0, time.UTC)
+
+	parsed, err := consumer.ParseTime("2018-07-01T12:00:00Z", now)
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC), parsed)
+
+	parsed, err = consumer.ParseTime("2h", now)
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2018, 7, 1, 12, 5, 0, 0, time.UTC), parsed)
+
+	_, err = consumer.ParseTime("around 14:05", now)
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid time \"around 14:05\", must be RFC3339 or a duration", err.Error())
+}
+
+func TestResolveTimeOffsets(t *testing.T) {
+	past := time.Date(2018, 7, 1, 14, 5, 0, 0, time.UTC)
+	future := past.Add(time.Hour)
+	broker, client := newTestBroker(t, map[int64]int64{
+		sarama.OffsetNewest:                         100,
+		past.UnixNano() / int64(time.Millisecond):   42,
+		future.UnixNano() / int64(time.Millisecond): -1,
+	}, nil)
+	defer broker.Close()
+	defer client.Close()
+
+	offsets, err := consumer.ResolveOffsets(client, testTopic, []int32{0}, consumer.TimeOffset(past))
+	require.Nil(t, err)
+	assert.Equal(t, map[int32]int64{0: 42}, offsets)
+
+	// No message that new yet, start at the end
+	offsets, err = consumer.ResolveOffsets(client, testTopic, []int32{0}, consumer.TimeOffset(future))
+	require.Nil(t, err)
+	assert.Equal(t, map[int32]int64{0: 100}, offsets)
+}
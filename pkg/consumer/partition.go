@@ -0,0 +1,133 @@
+package consumer
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+	cluster "github.com/bsm/sarama-cluster"
+)
+
+// PartitionConsumer implements the parser.Consumer interface
+// without joining a consumer group. It reads a fixed set of
+// partitions starting at explicit offsets and merges their
+// messages and errors onto a single pair of channels.
+type PartitionConsumer struct {
+	consumer   sarama.Consumer
+	topic      string
+	partitions map[int32]sarama.PartitionConsumer
+	messages   chan *sarama.ConsumerMessage
+	errors     chan error
+	closing    chan struct{}
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+}
+
+// NewPartitionConsumer starts a sarama.PartitionConsumer for every
+// entry in offsets, keyed by partition. Offsets must be absolute
+// or one of sarama.OffsetOldest and sarama.OffsetNewest.
+func NewPartitionConsumer(client sarama.Client, topic string, offsets map[int32]int64) (*PartitionConsumer, error) {
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PartitionConsumer{
+		consumer:   consumer,
+		topic:      topic,
+		partitions: make(map[int32]sarama.PartitionConsumer, len(offsets)),
+		messages:   make(chan *sarama.ConsumerMessage),
+		errors:     make(chan error),
+		closing:    make(chan struct{}),
+	}
+
+	for _, partition := range sortedPartitions(offsets) {
+		pc, err := consumer.ConsumePartition(topic, partition, offsets[partition])
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.partitions[partition] = pc
+	}
+
+	for _, pc := range p.partitions {
+		p.wg.Add(2)
+		go p.forwardMessages(pc)
+		go p.forwardErrors(pc)
+	}
+
+	// Close the merged channels once every
+	// partition consumer has been drained
+	go func() {
+		p.wg.Wait()
+		close(p.messages)
+		close(p.errors)
+	}()
+
+	return p, nil
+}
+
+// Messages returns the merged messages of every partition
+func (p *PartitionConsumer) Messages() <-chan *sarama.ConsumerMessage {
+	return p.messages
+}
+
+// Errors returns the merged errors of every partition
+func (p *PartitionConsumer) Errors() <-chan error {
+	return p.errors
+}
+
+// Notifications returns nil since there is no group
+// to rebalance
+func (p *PartitionConsumer) Notifications() <-chan *cluster.Notification {
+	return nil
+}
+
+// HighWaterMarks returns the last high water mark fetched
+// for every partition, keyed by topic like cluster.Consumer
+func (p *PartitionConsumer) HighWaterMarks() map[string]map[int32]int64 {
+	marks := make(map[int32]int64, len(p.partitions))
+	for partition, pc := range p.partitions {
+		marks[partition] = pc.HighWaterMarkOffset()
+	}
+
+	return map[string]map[int32]int64{p.topic: marks}
+}
+
+// Close shuts down every partition consumer, waits for
+// them to drain and then closes the underlying sarama.Consumer.
+// The client passed to NewPartitionConsumer is left open.
+func (p *PartitionConsumer) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.closing)
+		for _, pc := range p.partitions {
+			pc.AsyncClose()
+		}
+		p.wg.Wait()
+		err = p.consumer.Close()
+	})
+
+	return err
+}
+
+// Messages still buffered by sarama after Close
+// are dropped since nobody is reading anymore
+func (p *PartitionConsumer) forwardMessages(pc sarama.PartitionConsumer) {
+	defer p.wg.Done()
+	for msg := range pc.Messages() {
+		select {
+		case p.messages <- msg:
+		case <-p.closing:
+		}
+	}
+}
+
+func (p *PartitionConsumer) forwardErrors(pc sarama.PartitionConsumer) {
+	defer p.wg.Done()
+	for err := range pc.Errors() {
+		select {
+		case p.errors <- err:
+		case <-p.closing:
+		}
+	}
+}
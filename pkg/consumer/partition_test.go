@@ -0,0 +1,48 @@
+package consumer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/consumer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionConsumer(t *testing.T) {
+	fetch := sarama.NewMockFetchResponse(t, 1).
+		SetMessage(testTopic, 0, 1, sarama.StringEncoder("first")).
+		SetMessage(testTopic, 0, 2, sarama.StringEncoder("second")).
+		SetHighWaterMark(testTopic, 0, 3)
+	broker, client := newTestBroker(t, map[int64]int64{
+		sarama.OffsetOldest: 0,
+		sarama.OffsetNewest: 3,
+	}, fetch)
+	defer broker.Close()
+	defer client.Close()
+
+	partitionConsumer, err := consumer.NewPartitionConsumer(client, testTopic, map[int32]int64{0: 1})
+	require.Nil(t, err)
+
+	assert.Nil(t, partitionConsumer.Notifications())
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case msg := <-partitionConsumer.Messages():
+			assert.Equal(t, expected, string(msg.Value))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for message")
+		}
+	}
+
+	assert.Equal(t, map[string]map[int32]int64{testTopic: {0: 3}}, partitionConsumer.HighWaterMarks())
+
+	require.Nil(t, partitionConsumer.Close())
+
+	// Both channels are closed once the consumer is
+	_, more := <-partitionConsumer.Messages()
+	assert.False(t, more)
+	_, more = <-partitionConsumer.Errors()
+	assert.False(t, more)
+}
@@ -0,0 +1,108 @@
+package consumer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrCertWithoutKey denotes that only one of the
+	// client certificate and its key was passed
+	ErrCertWithoutKey = errors.New("a client certificate and its key must be passed together")
+	// ErrNoCACerts denotes that the CA file holds no PEM certificates
+	ErrNoCACerts = errors.New("no PEM certificates found in the CA file")
+	// ErrNoSASLPassword denotes that a SASL user was passed without a password
+	ErrNoSASLPassword = errors.New("a SASL password is required with a SASL user")
+)
+
+// Security holds the settings needed to reach clusters
+// which require TLS or SASL/PLAIN authentication. The
+// zero value connects in plain text without authenticating.
+type Security struct {
+	// TLS connects to brokers over TLS, it's implied
+	// by any of the other TLS settings
+	TLS bool
+	// CAFile is a PEM bundle of the CAs trusted to sign
+	// broker certificates, the system roots are used if empty
+	CAFile string
+	// CertFile and KeyFile are the PEM client
+	// certificate and key for mutual TLS
+	CertFile string
+	KeyFile  string
+	// ServerName is checked against broker certificates
+	// instead of the host brokers are reached at
+	ServerName string
+	// InsecureSkipVerify accepts any broker certificate
+	InsecureSkipVerify bool
+
+	// SASLUser and SASLPassword authenticate with SASL/PLAIN
+	SASLUser     string
+	SASLPassword string
+}
+
+// TLSEnabled reports whether any TLS setting is set
+func (s Security) TLSEnabled() bool {
+	return s.TLS || s.CAFile != "" || s.CertFile != "" || s.KeyFile != "" || s.ServerName != "" || s.InsecureSkipVerify
+}
+
+// Apply sets the TLS and SASL settings of config,
+// reading the certificate files
+func (s Security) Apply(config *sarama.Config) error {
+	if s.TLSEnabled() {
+		tlsConfig, err := s.tlsConfig()
+		if err != nil {
+			return err
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	if s.SASLUser != "" {
+		if s.SASLPassword == "" {
+			return ErrNoSASLPassword
+		}
+		config.Net.SASL.Enable = true
+		config.Net.SASL.Handshake = true
+		config.Net.SASL.User = s.SASLUser
+		config.Net.SASL.Password = s.SASLPassword
+	}
+
+	return nil
+}
+
+func (s Security) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         s.ServerName,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+
+	if s.CAFile != "" {
+		pem, err := ioutil.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading CA file %s", s.CAFile)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, ErrNoCACerts
+		}
+	}
+
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return nil, ErrCertWithoutKey
+	}
+
+	if s.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
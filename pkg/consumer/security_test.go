@@ -0,0 +1,229 @@
+package consumer_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/consumer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testServerName = "kafka.test"
+
+// testPKI is a CA along with a server and client
+// certificate it signed, written to PEM files
+type testPKI struct {
+	dir      string
+	ca       *x509.Certificate
+	caKey    *ecdsa.PrivateKey
+	pool     *x509.CertPool
+	server   tls.Certificate
+	serial   int64
+	caFile   string
+	certFile string
+	keyFile  string
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	dir, err := ioutil.TempDir("", "security")
+	require.Nil(t, err)
+
+	p := &testPKI{dir: dir}
+
+	p.caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	caDER := p.sign(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, &p.caKey.PublicKey)
+	p.ca, err = x509.ParseCertificate(caDER)
+	require.Nil(t, err)
+	p.pool = x509.NewCertPool()
+	p.pool.AddCert(p.ca)
+	p.caFile = p.write(t, "ca.pem", "CERTIFICATE", caDER)
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	serverDER := p.sign(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: testServerName},
+		DNSNames:    []string{testServerName},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &serverKey.PublicKey)
+	p.server = tls.Certificate{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	clientDER := p.sign(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &clientKey.PublicKey)
+	clientKeyDER, err := x509.MarshalECPrivateKey(clientKey)
+	require.Nil(t, err)
+	p.certFile = p.write(t, "client.pem", "CERTIFICATE", clientDER)
+	p.keyFile = p.write(t, "client-key.pem", "EC PRIVATE KEY", clientKeyDER)
+
+	return p
+}
+
+func (p *testPKI) sign(t *testing.T, template *x509.Certificate, key *ecdsa.PublicKey) []byte {
+	p.serial++
+	template.SerialNumber = big.NewInt(p.serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parent := p.ca
+	if parent == nil {
+		parent = template
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key, p.caKey)
+	require.Nil(t, err)
+	return der
+}
+
+func (p *testPKI) write(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(p.dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.Nil(t, ioutil.WriteFile(path, data, 0600))
+	return path
+}
+
+// quietReporter drops the errors a MockBroker reports
+// when a client fails the TLS handshake on purpose
+type quietReporter struct{}
+
+func (quietReporter) Error(...interface{})          {}
+func (quietReporter) Errorf(string, ...interface{}) {}
+func (quietReporter) Fatal(...interface{})          {}
+func (quietReporter) Fatalf(string, ...interface{}) {}
+
+// newTLSBroker starts a MockBroker behind a TLS listener,
+// requiring client certificates if clientAuth is set
+func newTLSBroker(t sarama.TestReporter, p *testPKI, clientAuth bool) *sarama.MockBroker {
+	config := &tls.Config{
+		Certificates: []tls.Certificate{p.server},
+	}
+	if clientAuth {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = p.pool
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := sarama.NewMockBrokerListener(t, 1, listener)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(testTopic, 0, broker.BrokerID()),
+	})
+
+	return broker
+}
+
+func connect(t *testing.T, broker *sarama.MockBroker, security consumer.Security) error {
+	config := sarama.NewConfig()
+	config.Metadata.Retry.Max = 0
+	config.Net.DialTimeout = time.Second
+	require.Nil(t, security.Apply(config))
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	if err == nil {
+		client.Close()
+	}
+	return err
+}
+
+func TestSecurityTLS(t *testing.T) {
+	p := newTestPKI(t)
+	defer os.RemoveAll(p.dir)
+
+	broker := newTLSBroker(t, p, false)
+	defer broker.Close()
+
+	assert.Nil(t, connect(t, broker, consumer.Security{
+		CAFile:     p.caFile,
+		ServerName: testServerName,
+	}))
+	assert.Nil(t, connect(t, broker, consumer.Security{
+		InsecureSkipVerify: true,
+	}))
+}
+
+func TestSecurityTLSUntrusted(t *testing.T) {
+	p := newTestPKI(t)
+	defer os.RemoveAll(p.dir)
+
+	broker := newTLSBroker(quietReporter{}, p, false)
+	defer broker.Close()
+
+	// The system roots don't trust the test CA
+	assert.NotNil(t, connect(t, broker, consumer.Security{
+		TLS:        true,
+		ServerName: testServerName,
+	}))
+
+	// The certificate isn't valid for 127.0.0.1
+	assert.NotNil(t, connect(t, broker, consumer.Security{
+		CAFile: p.caFile,
+	}))
+}
+
+func TestSecurityMutualTLS(t *testing.T) {
+	p := newTestPKI(t)
+	defer os.RemoveAll(p.dir)
+
+	broker := newTLSBroker(quietReporter{}, p, true)
+	defer broker.Close()
+
+	assert.Nil(t, connect(t, broker, consumer.Security{
+		CAFile:     p.caFile,
+		CertFile:   p.certFile,
+		KeyFile:    p.keyFile,
+		ServerName: testServerName,
+	}))
+	assert.NotNil(t, connect(t, broker, consumer.Security{
+		CAFile:     p.caFile,
+		ServerName: testServerName,
+	}))
+}
+
+func TestSecurityApply(t *testing.T) {
+	p := newTestPKI(t)
+	defer os.RemoveAll(p.dir)
+
+	config := sarama.NewConfig()
+	require.Nil(t, consumer.Security{}.Apply(config))
+	assert.False(t, config.Net.TLS.Enable)
+	assert.False(t, config.Net.SASL.Enable)
+
+	require.Nil(t, consumer.Security{SASLUser: "user", SASLPassword: "secret"}.Apply(config))
+	assert.True(t, config.Net.SASL.Enable)
+	assert.True(t, config.Net.SASL.Handshake)
+	assert.Equal(t, "user", config.Net.SASL.User)
+	assert.Equal(t, "secret", config.Net.SASL.Password)
+
+	assert.Equal(t, consumer.ErrNoSASLPassword, consumer.Security{SASLUser: "user"}.Apply(config))
+	assert.Equal(t, consumer.ErrCertWithoutKey, consumer.Security{CertFile: p.certFile}.Apply(config))
+	assert.Equal(t, consumer.ErrNoCACerts, consumer.Security{CAFile: p.keyFile}.Apply(config))
+
+	err := consumer.Security{CAFile: filepath.Join(p.dir, "missing.pem")}.Apply(config)
+	assert.Contains(t, err.Error(), "error reading CA file")
+}
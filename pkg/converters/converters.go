@@ -0,0 +1,274 @@
+// Package converters holds the built-in converters, each
+// changes the fields at the given paths of a decoded value
+package converters
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ErrConvertingFieldWrapper wraps errors returned while converting a field
+	ErrConvertingFieldWrapper = "error converting field %s"
+)
+
+var (
+	// ErrNoFields denotes that a converter was created without field paths
+	ErrNoFields = errors.New("a converter needs at least one field path")
+	// ErrUnknownConverter denotes that a converter name isn't built-in
+	ErrUnknownConverter = errors.New("unknown converter")
+	// Names holds the built-in converters, passed
+	// on the command line as name:field,other.field
+	Names = []string{
+		"base64",
+		"epoch-millis",
+		"hex",
+		"json",
+	}
+)
+
+type (
+	// JSONConverter parses string or bytes fields holding JSON
+	JSONConverter struct {
+		Fields []string
+	}
+
+	// EpochMillisConverter renders fields holding milliseconds
+	// since the Unix epoch as RFC3339 times in UTC
+	EpochMillisConverter struct {
+		Fields []string
+	}
+
+	// BytesConverter re-encodes bytes fields as base64 or hex.
+	// Fields that are already strings are taken to be base64,
+	// which is how bytes are written to JSON.
+	BytesConverter struct {
+		Fields []string
+		Hex    bool
+	}
+)
+
+// IsBuiltin reports whether spec names a built-in converter
+func IsBuiltin(spec string) bool {
+	name := strings.SplitN(spec, ":", 2)[0]
+	for _, builtin := range Names {
+		if name == builtin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// New creates a built-in converter from a spec
+// such as json:payload,meta.raw
+func New(spec string) (parser.Converter, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	var fields []string
+	if len(parts) == 2 && parts[1] != "" {
+		fields = strings.Split(parts[1], ",")
+	}
+	if len(fields) == 0 {
+		return nil, ErrNoFields
+	}
+
+	switch parts[0] {
+	case "json":
+		return &JSONConverter{Fields: fields}, nil
+	case "epoch-millis":
+		return &EpochMillisConverter{Fields: fields}, nil
+	case "base64":
+		return &BytesConverter{Fields: fields}, nil
+	case "hex":
+		return &BytesConverter{Fields: fields, Hex: true}, nil
+	}
+
+	return nil, errors.Wrap(ErrUnknownConverter, parts[0])
+}
+
+// ConvertFields parses each field as JSON
+func (c *JSONConverter) ConvertFields(record map[string]interface{}) error {
+	return convertAll(record, c.Fields, func(value interface{}) (interface{}, error) {
+		var raw []byte
+		switch v := value.(type) {
+		case string:
+			raw = []byte(v)
+		case []byte:
+			raw = v
+		default:
+			return nil, fmt.Errorf("%T is not a string or bytes", value)
+		}
+
+		var parsed interface{}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&parsed); err != nil {
+			return nil, err
+		}
+
+		return parsed, nil
+	})
+}
+
+// ConvertFields renders each field as an RFC3339 time
+func (c *EpochMillisConverter) ConvertFields(record map[string]interface{}) error {
+	return convertAll(record, c.Fields, func(value interface{}) (interface{}, error) {
+		millis, err := toInt64(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano), nil
+	})
+}
+
+// ConvertFields encodes each field as base64 or hex
+func (c *BytesConverter) ConvertFields(record map[string]interface{}) error {
+	return convertAll(record, c.Fields, func(value interface{}) (interface{}, error) {
+		var raw []byte
+		switch v := value.(type) {
+		case []byte:
+			raw = v
+		case string:
+			var err error
+			raw, err = base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%T is not bytes", value)
+		}
+
+		if c.Hex {
+			return hex.EncodeToString(raw), nil
+		}
+
+		return base64.StdEncoding.EncodeToString(raw), nil
+	})
+}
+
+// convertAll runs convert on every field path
+func convertAll(record map[string]interface{}, fields []string, convert func(interface{}) (interface{}, error)) error {
+	for _, field := range fields {
+		if err := convertPath(record, strings.Split(field, "."), convert); err != nil {
+			return errors.Wrapf(err, ErrConvertingFieldWrapper, field)
+		}
+	}
+
+	return nil
+}
+
+// convertPath replaces the value at path with the result
+// of convert. Lists along the way, or at the end of the
+// path, are walked so every element is converted. Missing
+// and null fields are left alone.
+func convertPath(record map[string]interface{}, path []string, convert func(interface{}) (interface{}, error)) error {
+	value, ok := record[path[0]]
+	if !ok || value == nil {
+		return nil
+	}
+
+	if len(path) == 1 {
+		converted, err := convertValue(value, convert)
+		if err != nil {
+			return err
+		}
+		record[path[0]] = converted
+		return nil
+	}
+
+	return walk(value, func(element interface{}) error {
+		if nested, ok := element.(map[string]interface{}); ok {
+			return convertPath(nested, path[1:], convert)
+		}
+		return nil
+	})
+}
+
+// convertValue converts value, or each element if it's a list
+func convertValue(value interface{}, convert func(interface{}) (interface{}, error)) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return convert(value)
+	}
+
+	for i, element := range list {
+		if element == nil {
+			continue
+		}
+
+		converted, err := convert(element)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = converted
+	}
+
+	return list, nil
+}
+
+// walk calls fn with value, or each element if it's a list
+func walk(value interface{}, fn func(interface{}) error) error {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fn(value)
+	}
+
+	for _, element := range list {
+		if err := fn(element); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// toInt64 reads the numeric types decoders return
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case float32:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		return int64(f), err
+	case string:
+		// Protobuf writes 64 bit integers as strings
+		return strconv.ParseInt(v, 10, 64)
+	}
+
+	return 0, fmt.Errorf("%T is not a number", value)
+}
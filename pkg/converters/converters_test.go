@@ -0,0 +1,106 @@
+package converters_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/converters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONConverter(t *testing.T) {
+	converter, err := converters.New("json:payload,meta.raw")
+	require.Nil(t, err)
+
+	record := map[string]interface{}{
+		"payload": `{"id": 1}`,
+		"meta": map[string]interface{}{
+			"raw": []byte(`[true]`),
+		},
+	}
+
+	require.Nil(t, converter.ConvertFields(record))
+	assert.Equal(t, map[string]interface{}{
+		"payload": map[string]interface{}{"id": json.Number("1")},
+		"meta": map[string]interface{}{
+			"raw": []interface{}{true},
+		},
+	}, record)
+}
+
+func TestJSONConverterInvalid(t *testing.T) {
+	converter := &converters.JSONConverter{Fields: []string{"payload"}}
+
+	err := converter.ConvertFields(map[string]interface{}{"payload": "{"})
+
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error converting field payload")
+}
+
+func TestEpochMillisConverter(t *testing.T) {
+	converter, err := converters.New("epoch-millis:createdAt,events.at")
+	require.Nil(t, err)
+
+	record := map[string]interface{}{
+		"createdAt": int64(1530453900000),
+		"events": []interface{}{
+			map[string]interface{}{"at": json.Number("1530453900123")},
+			map[string]interface{}{"at": "1530453900000"},
+			map[string]interface{}{"other": 1},
+		},
+	}
+
+	require.Nil(t, converter.ConvertFields(record))
+	assert.Equal(t, map[string]interface{}{
+		"createdAt": "2018-07-01T14:05:00Z",
+		"events": []interface{}{
+			map[string]interface{}{"at": "2018-07-01T14:05:00.123Z"},
+			map[string]interface{}{"at": "2018-07-01T14:05:00Z"},
+			map[string]interface{}{"other": 1},
+		},
+	}, record)
+}
+
+func TestBytesConverter(t *testing.T) {
+	hex, err := converters.New("hex:data,encoded")
+	require.Nil(t, err)
+	base64, err := converters.New("base64:raw")
+	require.Nil(t, err)
+
+	record := map[string]interface{}{
+		"data":    []byte{0xde, 0xad},
+		"encoded": "3q0=",
+		"raw":     []byte{0xbe, 0xef},
+	}
+
+	require.Nil(t, hex.ConvertFields(record))
+	require.Nil(t, base64.ConvertFields(record))
+	assert.Equal(t, map[string]interface{}{
+		"data":    "dead",
+		"encoded": "dead",
+		"raw":     "vu8=",
+	}, record)
+}
+
+func TestMissingFieldsAreSkipped(t *testing.T) {
+	converter, err := converters.New("json:missing.nested")
+	require.Nil(t, err)
+
+	record := map[string]interface{}{"other": "value"}
+
+	require.Nil(t, converter.ConvertFields(record))
+	assert.Equal(t, map[string]interface{}{"other": "value"}, record)
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := converters.New("json")
+	assert.Equal(t, converters.ErrNoFields, err)
+
+	_, err = converters.New("unknown:field")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), converters.ErrUnknownConverter.Error())
+
+	assert.True(t, converters.IsBuiltin("hex:data"))
+	assert.False(t, converters.IsBuiltin("/path/to/converter.so"))
+}
@@ -1,9 +1,6 @@
 package decoders
 
 import (
-	"io/ioutil"
-	"strings"
-
 	"github.com/linkedin/goavro"
 	"github.com/pkg/errors"
 )
@@ -15,23 +12,62 @@ const (
 	ErrCreatingCodecWrapper = "error creating codec for schema %s"
 	// ErrDecodingMessageWrapper wraps errors returned decoding the message
 	ErrDecodingMessageWrapper = "error decoding message"
+	// ErrUnknownFingerprintWrapper wraps the fingerprint of a single-object
+	// encoded message when none of the schemas has it
+	ErrUnknownFingerprintWrapper = "unknown schema fingerprint %016x"
 )
 
 var (
 	// ErrInvalidSchema denotes that the schema file is not in the correct format
-	ErrInvalidSchema = errors.New("invalid schema, schemas must be .avsc files")
+	ErrInvalidSchema = errors.New("invalid schema, schemas must be .avsc or .avpr files, directories of them or inline JSON")
 	// ErrNoCodec denotes that Decode has been called, but no go-avro codec has been created for it
 	ErrNoCodec = errors.New("could not find codec. Was ValidateSchemas called yet?")
 	// ErrAssertingType denotes that one or more fields failed type assertion
 	ErrAssertingType = errors.New("could not decode message, type assertion failed")
+	// ErrNoSchemaFiles denotes that a schema directory has no .avsc or .avpr files
+	ErrNoSchemaFiles = errors.New("no .avsc or .avpr schemas found")
+	// ErrNoMatchingSchema denotes that none of the schemas could decode a message
+	ErrNoMatchingSchema = errors.New("no schema decodes the message")
+	// ErrAmbiguousSchema denotes that more than one schema decodes a
+	// message, Avro binary doesn't say which one it was written with
+	ErrAmbiguousSchema = errors.New("more than one schema decodes the message, use the single-object encoding to tell them apart")
+	// ErrMultipleReaderSchemas denotes that writer schemas were
+	// passed along with more than one reader schema
+	ErrMultipleReaderSchemas = errors.New("only one reader schema can be used with writer schemas")
 )
 
 // AvroDecoder implements the decoder interface
 // and can should be able to decode messages for
-// most schemas
+// most schemas.
+//
+// Messages in the Avro single-object encoding, a C3 01
+// marker and the schema's 8 byte CRC-64-AVRO fingerprint,
+// are decoded with the schema that has that fingerprint,
+// or fail if no schema has it.
+// Other messages are decoded with the only schema, or with
+// the one schema that reads all of it. Messages more than
+// one schema reads fail, as the result would be a guess.
 type AvroDecoder struct {
 	Converter Converter
-	codec     *goavro.Codec
+	Options   AvroOptions
+	// WriterSchemas is the path to the .avsc file, or a
+	// directory of them, messages were written with. The
+	// schema passed to ValidateSchemas is then used as the
+	// reader schema and every message is resolved to it.
+	WriterSchemas string
+	codecs        []*avroCodec
+	fingerprints  map[uint64]*avroCodec
+}
+
+// avroCodec pairs a goavro codec with its parsed
+// schema, which is needed to print logical types.
+// Codecs with a reader schema resolve what they
+// decode to it.
+type avroCodec struct {
+	*goavro.Codec
+	schema      *avroSchema
+	reader      *avroSchema
+	fingerprint uint64
 }
 
 // Converter is an interface type for converting individual
@@ -39,45 +75,143 @@ type AvroDecoder struct {
 // readable types. For example, if a field is []byte but is supposed to
 // be displayed as JSON, it needs to be converted to
 // json.RawMessage so it can be printed properly.
+//
+// Converters are also run by the Parser for every decoder,
+// see parser.WithConverters, which is how the command line
+// uses them.
 type Converter interface {
 	ConvertFields(record map[string]interface{}) error
 }
 
-// ValidateSchemas takes in a single Avro schema, validates the
-// schema and initializes an go-avro codec to process messages
+// ValidateSchemas takes in comma separated .avsc and .avpr
+// files or directories of them, or a single inline JSON schema,
+// validates the schemas and initializes go-avro codecs to
+// process messages. Named types can be defined in one file
+// and used in another.
 func (a *AvroDecoder) ValidateSchemas(schemas string) error {
-	if !strings.HasSuffix(schemas, ".avsc") {
-		return ErrInvalidSchema
+	codecs, err := loadAvroCodecs(schemas)
+	if err != nil {
+		return err
 	}
 
-	schemaBytes, err := ioutil.ReadFile(schemas)
-	if err != nil {
-		return errors.Wrapf(err, ErrReadingSchemaWrapper, schemas)
+	if a.WriterSchemas != "" {
+		if len(codecs) > 1 {
+			return ErrMultipleReaderSchemas
+		}
+
+		writers, err := loadAvroCodecs(a.WriterSchemas)
+		if err != nil {
+			return err
+		}
+
+		for _, writer := range writers {
+			writer.reader = codecs[0].schema
+		}
+		codecs = writers
 	}
 
-	a.codec, err = goavro.NewCodec(string(schemaBytes))
-	if err != nil {
-		return errors.Wrapf(err, ErrCreatingCodecWrapper, schemas)
+	a.codecs = codecs
+	a.fingerprints = make(map[uint64]*avroCodec, len(codecs))
+	for _, codec := range codecs {
+		a.fingerprints[codec.fingerprint] = codec
 	}
 
 	return nil
 }
 
-// Decode takes in an Avro message's value and uses the codec
+// Decode takes in an Avro message's value and uses the codecs
 // created in ValidateSchemas to decode the message
 func (a *AvroDecoder) Decode(msg []byte) (interface{}, error) {
-	if a.codec == nil {
+	if len(a.codecs) == 0 {
 		return nil, ErrNoCodec
 	}
 
-	native, _, err := a.codec.NativeFromBinary(msg)
+	if fingerprint, ok := singleObjectFingerprint(msg); ok {
+		codec, ok := a.fingerprints[fingerprint]
+		if !ok {
+			return nil, errors.Errorf(ErrUnknownFingerprintWrapper, fingerprint)
+		}
+		return decodeAvro(codec, &a.Options, msg[singleObjectHeaderLen:], a.Converter)
+	}
+
+	codec := a.codecs[0]
+	if len(a.codecs) > 1 {
+		var err error
+		codec, err = matchWriter(a.codecs, msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return decodeAvro(codec, &a.Options, msg, a.Converter)
+}
+
+// matchWriter returns the codec that decodes msg without
+// an error or bytes left over. It fails unless exactly one
+// schema, ignoring copies of it, does.
+func matchWriter(writers []*avroCodec, msg []byte) (*avroCodec, error) {
+	var match *avroCodec
+	for _, writer := range writers {
+		if _, rest, err := writer.NativeFromBinary(msg); err != nil || len(rest) != 0 {
+			continue
+		}
+		if match != nil && match.fingerprint != writer.fingerprint {
+			return nil, ErrAmbiguousSchema
+		}
+		match = writer
+	}
+
+	if match == nil {
+		return nil, ErrNoMatchingSchema
+	}
+
+	return match, nil
+}
+
+// newAvroCodec creates the codec for a schema
+func newAvroCodec(schema string) (*avroCodec, error) {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := parseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &avroCodec{
+		Codec:       codec,
+		schema:      parsed,
+		fingerprint: avroFingerprint(codec.CanonicalSchema()),
+	}, nil
+}
+
+// decodeAvro decodes msg with codec and makes the result
+// printable, shared by the Avro decoders
+func decodeAvro(codec *avroCodec, opts *AvroOptions, msg []byte, converter Converter) (interface{}, error) {
+	native, _, err := codec.NativeFromBinary(msg)
 	if err != nil {
 		return nil, errors.Wrapf(err, ErrDecodingMessageWrapper)
 	}
 
+	schema := codec.schema
+	if codec.reader != nil {
+		native, err = resolveAvro(codec.schema, codec.reader, native)
+		if err != nil {
+			return nil, errors.Wrapf(err, ErrDecodingMessageWrapper)
+		}
+		schema = codec.reader
+	}
+
+	// Print logical types and unions per the options
+	native = schema.render(opts, native)
+
+	// Schemas that aren't records, common
+	// for keys, are returned as is
 	casted, ok := native.(map[string]interface{})
 	if !ok {
-		return nil, ErrAssertingType
+		return native, nil
 	}
 
 	// Type assert on specific fields so they're
@@ -86,8 +220,8 @@ func (a *AvroDecoder) Decode(msg []byte) (interface{}, error) {
 
 	// If a converter is passed in, use it to further
 	// decode fields.
-	if a.Converter != nil {
-		err = a.Converter.ConvertFields(casted)
+	if converter != nil {
+		err = converter.ConvertFields(casted)
 		if err != nil {
 			return nil, err
 		}
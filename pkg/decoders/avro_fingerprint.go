@@ -0,0 +1,47 @@
+package decoders
+
+import (
+	"encoding/binary"
+)
+
+const (
+	// avroEmptyFingerprint is the CRC-64-AVRO of no
+	// bytes, see "Schema Fingerprints" in the specification
+	avroEmptyFingerprint uint64 = 0xc15d213aa4d7a795
+	// singleObjectHeaderLen is the length of the C3 01
+	// marker and fingerprint before single-object data
+	singleObjectHeaderLen = 10
+)
+
+var avroFingerprintTable = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (avroEmptyFingerprint & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return table
+}()
+
+// avroFingerprint returns the CRC-64-AVRO Rabin
+// fingerprint of a schema's parsing canonical form
+func avroFingerprint(canonical string) uint64 {
+	fp := avroEmptyFingerprint
+	for i := 0; i < len(canonical); i++ {
+		fp = (fp >> 8) ^ avroFingerprintTable[byte(fp)^canonical[i]]
+	}
+
+	return fp
+}
+
+// singleObjectFingerprint reads the schema fingerprint
+// of a message in the Avro single-object encoding
+func singleObjectFingerprint(msg []byte) (uint64, bool) {
+	if len(msg) < singleObjectHeaderLen || msg[0] != 0xc3 || msg[1] != 0x01 {
+		return 0, false
+	}
+
+	return binary.LittleEndian.Uint64(msg[2:singleObjectHeaderLen]), true
+}
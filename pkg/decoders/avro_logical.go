@@ -0,0 +1,339 @@
+package decoders
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout       = "2006-01-02"
+	timeMillisLayout = "15:04:05.000"
+	timeMicrosLayout = "15:04:05.000000"
+)
+
+// AvroOptions control how Avro values are printed. goavro
+// doesn't know about logical types, so without options
+// decimals are bytes and times are plain numbers.
+type AvroOptions struct {
+	// DecimalAsString prints decimal logical types as exact
+	// strings such as "12.30" instead of their raw bytes
+	DecimalAsString bool
+	// TimeLayout prints timestamp logical types with this
+	// time.Format layout, dates as 2006-01-02 and times of
+	// day as 15:04:05.000. Empty leaves them as numbers.
+	TimeLayout string
+	// Location is the time zone timestamps are printed in,
+	// UTC if nil. Local timestamps are never converted.
+	Location *time.Location
+	// UnwrapUnions prints the value of a union instead of
+	// goavro's {"type": value} wrapper
+	UnwrapUnions bool
+}
+
+type (
+	// avroSchema is a parsed Avro schema with its named
+	// types indexed so the decoded value can be walked
+	// alongside it
+	avroSchema struct {
+		root  interface{}
+		names map[string]*avroNamedType
+	}
+
+	// avroNamedType is a record, enum or fixed definition
+	// along with the namespace its children are in
+	avroNamedType struct {
+		schema    map[string]interface{}
+		fullName  string
+		namespace string
+	}
+)
+
+// parseAvroSchema parses the JSON of a schema goavro has
+// already accepted and indexes its named types
+func parseAvroSchema(schema string) (*avroSchema, error) {
+	s := &avroSchema{
+		names: make(map[string]*avroNamedType),
+	}
+
+	if err := json.Unmarshal([]byte(schema), &s.root); err != nil {
+		return nil, err
+	}
+
+	s.collect(s.root, "")
+	return s, nil
+}
+
+// collect indexes every named type defined in schema
+func (s *avroSchema) collect(schema interface{}, namespace string) {
+	switch v := schema.(type) {
+	case []interface{}:
+		for _, branch := range v {
+			s.collect(branch, namespace)
+		}
+	case map[string]interface{}:
+		t, ok := v["type"].(string)
+		if !ok {
+			s.collect(v["type"], namespace)
+			return
+		}
+
+		switch t {
+		case "record", "error", "enum", "fixed":
+			named := newAvroNamedType(v, namespace)
+			s.names[named.fullName] = named
+			fields, _ := v["fields"].([]interface{})
+			for _, field := range fields {
+				if field, ok := field.(map[string]interface{}); ok {
+					s.collect(field["type"], named.namespace)
+				}
+			}
+		case "array":
+			s.collect(v["items"], namespace)
+		case "map":
+			s.collect(v["values"], namespace)
+		}
+	}
+}
+
+// newAvroNamedType works out the full name of a named
+// type defined inside the enclosing namespace
+func newAvroNamedType(schema map[string]interface{}, enclosing string) *avroNamedType {
+	name, _ := schema["name"].(string)
+	namespace := enclosing
+	if ns, ok := schema["namespace"].(string); ok {
+		namespace = ns
+	}
+
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		namespace = name[:i]
+	} else if namespace != "" {
+		name = namespace + "." + name
+	}
+
+	return &avroNamedType{
+		schema:    schema,
+		fullName:  name,
+		namespace: namespace,
+	}
+}
+
+// lookup finds a named type referenced from namespace
+func (s *avroSchema) lookup(name, namespace string) *avroNamedType {
+	if named, ok := s.names[name]; ok {
+		return named
+	}
+
+	if namespace != "" {
+		return s.names[namespace+"."+name]
+	}
+
+	return nil
+}
+
+// typeName is the name goavro gives a union branch
+func (s *avroSchema) typeName(schema interface{}, namespace string) string {
+	switch v := schema.(type) {
+	case string:
+		if named := s.lookup(v, namespace); named != nil {
+			return named.fullName
+		}
+		return v
+	case map[string]interface{}:
+		t, ok := v["type"].(string)
+		if !ok {
+			return s.typeName(v["type"], namespace)
+		}
+
+		switch t {
+		case "record", "error", "enum", "fixed":
+			return newAvroNamedType(v, namespace).fullName
+		}
+		return s.typeName(t, namespace)
+	}
+
+	return ""
+}
+
+// render walks value alongside the schema it was decoded
+// with and prints logical types and unions per opts
+func (s *avroSchema) render(opts *AvroOptions, value interface{}) interface{} {
+	return s.renderValue(opts, s.root, "", value)
+}
+
+func (s *avroSchema) renderValue(opts *AvroOptions, schema interface{}, namespace string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	switch v := schema.(type) {
+	case string:
+		if named := s.lookup(v, namespace); named != nil {
+			return s.renderValue(opts, named.schema, named.namespace, value)
+		}
+		return value
+	case []interface{}:
+		return s.renderUnion(opts, v, namespace, value)
+	case map[string]interface{}:
+		t, ok := v["type"].(string)
+		if !ok {
+			return s.renderValue(opts, v["type"], namespace, value)
+		}
+
+		switch t {
+		case "record", "error":
+			record, ok := value.(map[string]interface{})
+			if !ok {
+				return value
+			}
+
+			childNamespace := newAvroNamedType(v, namespace).namespace
+			fields, _ := v["fields"].([]interface{})
+			for _, field := range fields {
+				field, ok := field.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := field["name"].(string)
+				if fieldValue, ok := record[name]; ok {
+					record[name] = s.renderValue(opts, field["type"], childNamespace, fieldValue)
+				}
+			}
+			return record
+		case "array":
+			if list, ok := value.([]interface{}); ok {
+				for i, item := range list {
+					list[i] = s.renderValue(opts, v["items"], namespace, item)
+				}
+			}
+			return value
+		case "map":
+			if values, ok := value.(map[string]interface{}); ok {
+				for key, item := range values {
+					values[key] = s.renderValue(opts, v["values"], namespace, item)
+				}
+			}
+			return value
+		case "enum":
+			return value
+		}
+
+		if logicalType, ok := v["logicalType"].(string); ok {
+			return renderLogical(opts, logicalType, v, value)
+		}
+
+		// {"type": "someNamedType"}
+		return s.renderValue(opts, t, namespace, value)
+	}
+
+	return value
+}
+
+// renderUnion unwraps goavro's {"type": value} union
+// wrapper to render the value with its branch
+func (s *avroSchema) renderUnion(opts *AvroOptions, branches []interface{}, namespace string, value interface{}) interface{} {
+	wrapped, ok := value.(map[string]interface{})
+	if !ok || len(wrapped) != 1 {
+		return value
+	}
+
+	for name, inner := range wrapped {
+		for _, branch := range branches {
+			if s.typeName(branch, namespace) == name {
+				inner = s.renderValue(opts, branch, namespace, inner)
+				break
+			}
+		}
+
+		if opts.UnwrapUnions {
+			return inner
+		}
+		wrapped[name] = inner
+	}
+
+	return wrapped
+}
+
+// renderLogical prints a logical type, values it
+// doesn't know how to handle are returned as is
+func renderLogical(opts *AvroOptions, logicalType string, schema map[string]interface{}, value interface{}) interface{} {
+	switch logicalType {
+	case "decimal":
+		raw, ok := value.([]byte)
+		if !ok || !opts.DecimalAsString {
+			return value
+		}
+		scale, _ := schema["scale"].(float64)
+		return formatDecimal(raw, int(scale))
+	case "uuid":
+		switch v := value.(type) {
+		case string:
+			return strings.ToLower(v)
+		case []byte:
+			if len(v) == 16 {
+				return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+			}
+		}
+		return value
+	}
+
+	n, ok := avroInt(value)
+	if !ok || opts.TimeLayout == "" {
+		return value
+	}
+
+	location := opts.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	switch logicalType {
+	case "timestamp-millis":
+		return time.Unix(0, n*int64(time.Millisecond)).In(location).Format(opts.TimeLayout)
+	case "timestamp-micros":
+		return time.Unix(0, n*int64(time.Microsecond)).In(location).Format(opts.TimeLayout)
+	case "local-timestamp-millis":
+		return time.Unix(0, n*int64(time.Millisecond)).UTC().Format(opts.TimeLayout)
+	case "local-timestamp-micros":
+		return time.Unix(0, n*int64(time.Microsecond)).UTC().Format(opts.TimeLayout)
+	case "date":
+		return time.Unix(n*24*60*60, 0).UTC().Format(dateLayout)
+	case "time-millis":
+		return time.Time{}.Add(time.Duration(n) * time.Millisecond).Format(timeMillisLayout)
+	case "time-micros":
+		return time.Time{}.Add(time.Duration(n) * time.Microsecond).Format(timeMicrosLayout)
+	}
+
+	return value
+}
+
+// formatDecimal prints the two's complement big endian
+// unscaled value of a decimal with scale digits after
+// the point
+func formatDecimal(raw []byte, scale int) string {
+	unscaled := new(big.Int).SetBytes(raw)
+	if len(raw) > 0 && raw[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(raw)*8)))
+	}
+
+	if scale <= 0 {
+		return unscaled.String()
+	}
+
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	return new(big.Rat).SetFrac(unscaled, denominator).FloatString(scale)
+}
+
+// avroInt reads the int and long values goavro returns
+func avroInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,87 @@
+package decoders_test
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/linkedin/goavro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pathToLogicalSchema = "../../etc/tests/logical_schema.avsc"
+
+// logicalMessage encodes a Payment from logical_schema.avsc
+func logicalMessage(t *testing.T) []byte {
+	schema, err := ioutil.ReadFile(pathToLogicalSchema)
+	require.Nil(t, err)
+	codec, err := goavro.NewCodec(string(schema))
+	require.Nil(t, err)
+
+	msg, err := codec.BinaryFromNative(nil, map[string]interface{}{
+		"id":        "6BA7B810-9DAD-11D1-80B4-00C04FD430C8",
+		"amount":    []byte{0xfb, 0x2e}, // -1234
+		"fee":       []byte{0x00, 0x7b}, // 123
+		"createdAt": int64(1530453900123),
+		"updatedAt": goavro.Union("long", int64(1530453900123456)),
+		"day":       int32(17713),
+		"at":        int32(50700123),
+		"refund":    goavro.Union("com.example.Fee", []byte{0x00, 0x05}),
+		"status":    goavro.Union("com.example.Status", "OK"),
+	})
+	require.Nil(t, err)
+
+	return msg
+}
+
+func TestDecodeLogicalTypesDefault(t *testing.T) {
+	decoder := &decoders.AvroDecoder{}
+	require.Nil(t, decoder.ValidateSchemas(pathToLogicalSchema))
+
+	decoded, err := decoder.Decode(logicalMessage(t))
+
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id":        "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"amount":    []byte{0xfb, 0x2e},
+		"fee":       []byte{0x00, 0x7b},
+		"createdAt": int64(1530453900123),
+		"updatedAt": map[string]interface{}{"long": int64(1530453900123456)},
+		"day":       int32(17713),
+		"at":        int32(50700123),
+		"refund":    map[string]interface{}{"com.example.Fee": []byte{0x00, 0x05}},
+		"status":    map[string]interface{}{"com.example.Status": "OK"},
+	}, decoded)
+}
+
+func TestDecodeLogicalTypesWithOptions(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.Nil(t, err)
+
+	decoder := &decoders.AvroDecoder{
+		Options: decoders.AvroOptions{
+			DecimalAsString: true,
+			TimeLayout:      "2006-01-02T15:04:05.000000Z07:00",
+			Location:        newYork,
+			UnwrapUnions:    true,
+		},
+	}
+	require.Nil(t, decoder.ValidateSchemas(pathToLogicalSchema))
+
+	decoded, err := decoder.Decode(logicalMessage(t))
+
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id":        "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"amount":    "-12.34",
+		"fee":       "12.3",
+		"createdAt": "2018-07-01T10:05:00.123000-04:00",
+		"updatedAt": "2018-07-01T10:05:00.123456-04:00",
+		"day":       "2018-07-01",
+		"at":        "14:05:00.123",
+		"refund":    "0.5",
+		"status":    "OK",
+	}, decoded)
+}
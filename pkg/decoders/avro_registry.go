@@ -0,0 +1,232 @@
+package decoders
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ErrFetchingSchemaWrapper wraps errors returned while fetching a schema from the registry
+	ErrFetchingSchemaWrapper = "error fetching schema %d from registry"
+	// ErrCreatingRegistryCodecWrapper wraps errors returned while creating the codec for a registry schema
+	ErrCreatingRegistryCodecWrapper = "error creating codec for registry schema %d"
+)
+
+const (
+	// DefaultRegistryTimeout bounds requests to the registry
+	// when AvroRegistryDecoder.Timeout isn't set
+	DefaultRegistryTimeout = 10 * time.Second
+	// DefaultRegistryRetry is how long a failed fetch is
+	// remembered when AvroRegistryDecoder.Retry isn't set
+	DefaultRegistryRetry = 30 * time.Second
+)
+
+var (
+	// ErrNoRegistryURL denotes that the registry decoder was used without a registry URL
+	ErrNoRegistryURL = errors.New("a schema registry URL is required")
+	// ErrNotRegistryFramed denotes that a message doesn't start with the magic byte and schema ID
+	ErrNotRegistryFramed = errors.New("message is not in the schema registry wire format")
+)
+
+// AvroRegistryDecoder implements the decoder interface for
+// messages written by Confluent serializers: a zero magic byte,
+// a 4 byte big endian schema ID and the Avro encoded value.
+// Schemas are fetched from the registry on first use and cached.
+// Failures are cached too so messages fail fast, IDs the registry
+// doesn't have for good and other failures for Retry.
+type AvroRegistryDecoder struct {
+	// URL is the base URL of the schema registry
+	URL string
+	// Client is used to talk to the registry, if nil
+	// a client with a Timeout is used
+	Client *http.Client
+	// Timeout bounds each request to the registry when
+	// Client is nil, defaults to DefaultRegistryTimeout
+	Timeout time.Duration
+	// Retry is how long to wait before fetching a schema
+	// again after a failure, defaults to DefaultRegistryRetry
+	Retry     time.Duration
+	Converter Converter
+	Options   AvroOptions
+
+	lock     sync.Mutex
+	codecs   map[uint32]*avroCodec
+	failures map[uint32]registryFailure
+	reader   *avroSchema
+}
+
+// registryFailure is a cached error, a zero
+// retry means the schema is never fetched again
+type registryFailure struct {
+	err   error
+	retry time.Time
+}
+
+// registryStatusError is returned when the
+// registry doesn't answer with a schema
+type registryStatusError struct {
+	code   int
+	status string
+}
+
+func (r *registryStatusError) Error() string {
+	return "registry returned " + r.status
+}
+
+// registrySchema is the body returned by GET /schemas/ids/{id}
+type registrySchema struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+}
+
+// ValidateSchemas checks that a registry URL has been set,
+// schemas are fetched as messages need them. If a schema
+// is passed it's used as the reader schema and every
+// message is resolved to it.
+func (a *AvroRegistryDecoder) ValidateSchemas(schemas string) error {
+	if a.URL == "" {
+		return ErrNoRegistryURL
+	}
+
+	if schemas == "" {
+		return nil
+	}
+
+	readers, err := loadAvroCodecs(schemas)
+	if err != nil {
+		return err
+	}
+	if len(readers) > 1 {
+		return ErrMultipleReaderSchemas
+	}
+	a.reader = readers[0].schema
+
+	return nil
+}
+
+// Decode reads the schema ID from the message and decodes
+// the rest of it with the schema it was written with
+func (a *AvroRegistryDecoder) Decode(msg []byte) (interface{}, error) {
+	if len(msg) < 5 || msg[0] != 0 {
+		return nil, ErrNotRegistryFramed
+	}
+
+	codec, err := a.codec(binary.BigEndian.Uint32(msg[1:5]))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeAvro(codec, &a.Options, msg[5:], a.Converter)
+}
+
+// codec returns the cached codec for id, fetching
+// the schema from the registry if needed. The lock isn't
+// held while fetching so a slow registry doesn't hold up
+// schemas that are already cached.
+func (a *AvroRegistryDecoder) codec(id uint32) (*avroCodec, error) {
+	a.lock.Lock()
+	codec, ok := a.codecs[id]
+	failure, failed := a.failures[id]
+	a.lock.Unlock()
+	if ok {
+		return codec, nil
+	}
+	if failed && (failure.retry.IsZero() || time.Now().Before(failure.retry)) {
+		return nil, failure.err
+	}
+
+	schema, err := a.fetchSchema(id)
+	if err != nil {
+		// Schemas are never deleted from a registry
+		// so an unknown ID stays unknown
+		err = errors.Wrapf(err, ErrFetchingSchemaWrapper, id)
+		if status, ok := errors.Cause(err).(*registryStatusError); ok && status.code == http.StatusNotFound {
+			return nil, a.fail(id, err, time.Time{})
+		}
+		return nil, a.fail(id, err, time.Now().Add(a.retryAfter()))
+	}
+
+	codec, err = newAvroCodec(schema)
+	if err != nil {
+		return nil, a.fail(id, errors.Wrapf(err, ErrCreatingRegistryCodecWrapper, id), time.Time{})
+	}
+	codec.reader = a.reader
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// Another caller may have fetched it meanwhile
+	if cached, ok := a.codecs[id]; ok {
+		return cached, nil
+	}
+	if a.codecs == nil {
+		a.codecs = make(map[uint32]*avroCodec)
+	}
+	a.codecs[id] = codec
+
+	return codec, nil
+}
+
+// fail caches err for id until retry and returns it
+func (a *AvroRegistryDecoder) fail(id uint32, err error, retry time.Time) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.failures == nil {
+		a.failures = make(map[uint32]registryFailure)
+	}
+	a.failures[id] = registryFailure{err: err, retry: retry}
+
+	return err
+}
+
+func (a *AvroRegistryDecoder) retryAfter() time.Duration {
+	if a.Retry > 0 {
+		return a.Retry
+	}
+
+	return DefaultRegistryRetry
+}
+
+func (a *AvroRegistryDecoder) fetchSchema(id uint32) (string, error) {
+	client := a.Client
+	if client == nil {
+		timeout := a.Timeout
+		if timeout <= 0 {
+			timeout = DefaultRegistryTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+
+	url := fmt.Sprintf("%s/schemas/ids/%d", strings.TrimSuffix(a.URL, "/"), id)
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &registryStatusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	var body registrySchema
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+
+	// Registries that support other formats leave
+	// schemaType out for Avro schemas
+	if body.SchemaType != "" && !strings.EqualFold(body.SchemaType, "AVRO") {
+		return "", errors.Errorf("schema type %s is not Avro", body.SchemaType)
+	}
+
+	return body.Schema, nil
+}
@@ -0,0 +1,212 @@
+package decoders_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/linkedin/goavro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	registrySchemaV1 = `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}]}`
+	registrySchemaV2 = `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}, {"name": "age", "type": "int"}]}`
+)
+
+// newTestRegistry serves schemas by ID and counts
+// how often each one is requested
+func newTestRegistry(t *testing.T, schemas map[int]string) (*httptest.Server, map[int]int) {
+	requests := make(map[int]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id int
+		_, err := fmt.Sscanf(r.URL.Path, "/schemas/ids/%d", &id)
+		requests[id]++
+		schema, ok := schemas[id]
+		if err != nil || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"schema": schema})
+	}))
+
+	return server, requests
+}
+
+func registryMessage(t *testing.T, id uint32, schema string, native map[string]interface{}) []byte {
+	codec, err := goavro.NewCodec(schema)
+	require.Nil(t, err)
+
+	msg := make([]byte, 5)
+	binary.BigEndian.PutUint32(msg[1:], id)
+	msg, err = codec.BinaryFromNative(msg, native)
+	require.Nil(t, err)
+
+	return msg
+}
+
+func TestRegistryValidateSchemasNoURL(t *testing.T) {
+	decoder := &decoders.AvroRegistryDecoder{}
+
+	assert.Equal(t, decoders.ErrNoRegistryURL, decoder.ValidateSchemas(""))
+}
+
+func TestRegistryDecodeNotFramed(t *testing.T) {
+	decoder := &decoders.AvroRegistryDecoder{URL: "http://localhost"}
+
+	decoded, err := decoder.Decode([]byte{1, 0, 0, 0, 1, 2})
+
+	assert.Nil(t, decoded)
+	assert.Equal(t, decoders.ErrNotRegistryFramed, err)
+}
+
+func TestRegistryDecodeUnknownSchema(t *testing.T) {
+	server, _ := newTestRegistry(t, nil)
+	defer server.Close()
+
+	decoder := &decoders.AvroRegistryDecoder{URL: server.URL}
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	decoded, err := decoder.Decode([]byte{0, 0, 0, 0, 9, 2})
+
+	assert.Nil(t, decoded)
+	require.NotNil(t, err)
+	assert.Equal(t, "error fetching schema 9 from registry: registry returned 404 Not Found", err.Error())
+}
+
+func TestRegistryDecodeCachesUnknownSchema(t *testing.T) {
+	server, requests := newTestRegistry(t, nil)
+	defer server.Close()
+
+	decoder := &decoders.AvroRegistryDecoder{URL: server.URL}
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	for i := 0; i < 3; i++ {
+		_, err := decoder.Decode([]byte{0, 0, 0, 0, 9, 2})
+		require.NotNil(t, err)
+		assert.Equal(t, "error fetching schema 9 from registry: registry returned 404 Not Found", err.Error())
+	}
+
+	assert.Equal(t, map[int]int{9: 1}, requests)
+}
+
+func TestRegistryDecodeRetriesFailures(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	decoder := &decoders.AvroRegistryDecoder{URL: server.URL, Retry: 50 * time.Millisecond}
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	for i := 0; i < 3; i++ {
+		_, err := decoder.Decode([]byte{0, 0, 0, 0, 1, 2})
+		require.NotNil(t, err)
+		assert.Equal(t, "error fetching schema 1 from registry: registry returned 503 Service Unavailable", err.Error())
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	time.Sleep(60 * time.Millisecond)
+	_, err := decoder.Decode([]byte{0, 0, 0, 0, 1, 2})
+	require.NotNil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestRegistryDecodeBySchemaID(t *testing.T) {
+	server, requests := newTestRegistry(t, map[int]string{
+		1: registrySchemaV1,
+		2: registrySchemaV2,
+	})
+	defer server.Close()
+
+	decoder := &decoders.AvroRegistryDecoder{URL: server.URL + "/"}
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	v1 := map[string]interface{}{"name": "ken"}
+	v2 := map[string]interface{}{"name": "ken", "age": int32(30)}
+	for _, msg := range []struct {
+		id       uint32
+		schema   string
+		expected map[string]interface{}
+	}{
+		{1, registrySchemaV1, v1},
+		{2, registrySchemaV2, v2},
+		{1, registrySchemaV1, v1},
+	} {
+		decoded, err := decoder.Decode(registryMessage(t, msg.id, msg.schema, msg.expected))
+		require.Nil(t, err)
+		assert.Equal(t, msg.expected, decoded)
+	}
+
+	// Schemas are only fetched once
+	assert.Equal(t, map[int]int{1: 1, 2: 1}, requests)
+}
+
+func TestRegistryDecodeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	decoder := &decoders.AvroRegistryDecoder{URL: server.URL, Timeout: 50 * time.Millisecond}
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	decoded, err := decoder.Decode([]byte{0, 0, 0, 0, 1, 2})
+
+	assert.Nil(t, decoded)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error fetching schema 1 from registry")
+}
+
+func TestRegistryDecodeCachedWhileFetching(t *testing.T) {
+	requested, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/schemas/ids/2" {
+			close(requested)
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]string{"schema": registrySchemaV1})
+	}))
+	defer server.Close()
+
+	decoder := &decoders.AvroRegistryDecoder{URL: server.URL}
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	msg := registryMessage(t, 1, registrySchemaV1, map[string]interface{}{"name": "ken"})
+	_, err := decoder.Decode(msg)
+	require.Nil(t, err)
+
+	fetched := make(chan error)
+	go func() {
+		_, err := decoder.Decode(registryMessage(t, 2, registrySchemaV1, map[string]interface{}{"name": "ken"}))
+		fetched <- err
+	}()
+	<-requested
+
+	// Schema 1 is cached, the fetch of schema 2 mustn't hold it up
+	decoded := make(chan error)
+	go func() {
+		_, err := decoder.Decode(msg)
+		decoded <- err
+	}()
+	select {
+	case err := <-decoded:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("cached schema waited on the registry")
+	}
+
+	close(release)
+	assert.Nil(t, <-fetched)
+}
@@ -0,0 +1,451 @@
+package decoders
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Avro schema resolution, see "Schema Resolution" in the
+// Avro specification. goavro decodes with the writer
+// schema, the decoded value is then reshaped to match
+// the reader schema.
+
+// avroKind returns the type of a dereferenced schema
+func avroKind(schema interface{}) string {
+	switch v := schema.(type) {
+	case string:
+		return v
+	case []interface{}:
+		return "union"
+	case map[string]interface{}:
+		t, _ := v["type"].(string)
+		if t == "error" {
+			return "record"
+		}
+		return t
+	}
+
+	return ""
+}
+
+// deref follows named type references and nested type
+// definitions until it reaches a primitive name, a union
+// or a complex type definition
+func (s *avroSchema) deref(schema interface{}, namespace string) (interface{}, string) {
+	for {
+		switch v := schema.(type) {
+		case string:
+			named := s.lookup(v, namespace)
+			if named == nil {
+				return v, namespace
+			}
+			return named.schema, named.namespace
+		case map[string]interface{}:
+			switch t := v["type"].(type) {
+			case string:
+				switch t {
+				case "record", "error", "enum", "fixed", "array", "map":
+					return v, namespace
+				}
+				if s.lookup(t, namespace) == nil {
+					// A primitive, possibly with a logical type
+					return t, namespace
+				}
+				schema = t
+			default:
+				schema = t
+			}
+		default:
+			return schema, namespace
+		}
+	}
+}
+
+// shortName is the unqualified name of a named type
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// resolveAvro reshapes value, decoded with the writer
+// schema, into what the reader schema would decode
+func resolveAvro(writer, reader *avroSchema, value interface{}) (interface{}, error) {
+	return resolveValue(writer, writer.root, "", reader, reader.root, "", value)
+}
+
+func resolveValue(writer *avroSchema, w interface{}, wns string, reader *avroSchema, r interface{}, rns string, value interface{}) (interface{}, error) {
+	w, wns = writer.deref(w, wns)
+	r, rns = reader.deref(r, rns)
+	wKind, rKind := avroKind(w), avroKind(r)
+
+	// Unwrap the writer's union to the branch that was written
+	if wKind == "union" {
+		name, inner := unionBranch(value)
+
+		for _, branch := range w.([]interface{}) {
+			if writer.typeName(branch, wns) == name {
+				return resolveValue(writer, branch, wns, reader, r, rns, inner)
+			}
+		}
+		return nil, errors.Errorf("writer union has no %s branch", name)
+	}
+
+	// Wrap the value in the first reader branch it matches
+	if rKind == "union" {
+		for _, branch := range r.([]interface{}) {
+			if !avroMatches(writer, w, wns, reader, branch, rns) {
+				continue
+			}
+
+			resolved, err := resolveValue(writer, w, wns, reader, branch, rns, value)
+			if err != nil {
+				return nil, err
+			}
+
+			name := reader.typeName(branch, rns)
+			if name == "null" {
+				return nil, nil
+			}
+			return map[string]interface{}{name: resolved}, nil
+		}
+		return nil, errors.Errorf("no branch of the reader union matches writer type %s", writer.typeName(w, wns))
+	}
+
+	if !avroMatches(writer, w, wns, reader, r, rns) {
+		return nil, errors.Errorf("writer type %s doesn't match reader type %s", writer.typeName(w, wns), reader.typeName(r, rns))
+	}
+
+	switch rKind {
+	case "record":
+		return resolveRecord(writer, w.(map[string]interface{}), wns, reader, r.(map[string]interface{}), rns, value)
+	case "enum":
+		return resolveEnum(r.(map[string]interface{}), value)
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		for i, item := range list {
+			resolved, err := resolveValue(writer, w.(map[string]interface{})["items"], wns, reader, r.(map[string]interface{})["items"], rns, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+	case "map":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		for key, item := range values {
+			resolved, err := resolveValue(writer, w.(map[string]interface{})["values"], wns, reader, r.(map[string]interface{})["values"], rns, item)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = resolved
+		}
+		return values, nil
+	}
+
+	return promote(wKind, rKind, value), nil
+}
+
+// resolveRecord matches reader fields to writer fields by
+// name or alias, fills in defaults for fields the writer
+// doesn't have and drops fields the reader doesn't have
+func resolveRecord(writer *avroSchema, w map[string]interface{}, wns string, reader *avroSchema, r map[string]interface{}, rns string, value interface{}) (interface{}, error) {
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return value, nil
+	}
+
+	wChildNS := newAvroNamedType(w, wns).namespace
+	rChildNS := newAvroNamedType(r, rns).namespace
+
+	writerFields := make(map[string]map[string]interface{})
+	fields, _ := w["fields"].([]interface{})
+	for _, field := range fields {
+		if field, ok := field.(map[string]interface{}); ok {
+			name, _ := field["name"].(string)
+			writerFields[name] = field
+		}
+	}
+
+	resolved := make(map[string]interface{})
+	fields, _ = r["fields"].([]interface{})
+	for _, field := range fields {
+		field, ok := field.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := field["name"].(string)
+
+		writerField, writerName := writerFields[name], name
+		if writerField == nil {
+			aliases, _ := field["aliases"].([]interface{})
+			for _, alias := range aliases {
+				alias, _ := alias.(string)
+				if writerFields[alias] != nil {
+					writerField, writerName = writerFields[alias], alias
+					break
+				}
+			}
+		}
+
+		if writerField != nil {
+			fieldValue, err := resolveValue(writer, writerField["type"], wChildNS, reader, field["type"], rChildNS, record[writerName])
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %s", name)
+			}
+			resolved[name] = fieldValue
+			continue
+		}
+
+		def, ok := field["default"]
+		if !ok {
+			return nil, errors.Errorf("reader field %s has no default and isn't in the writer schema", name)
+		}
+
+		fieldValue, err := avroDefault(reader, field["type"], rChildNS, def)
+		if err != nil {
+			return nil, errors.Wrapf(err, "default of field %s", name)
+		}
+		resolved[name] = fieldValue
+	}
+
+	return resolved, nil
+}
+
+// resolveEnum falls back to the reader's default
+// for symbols it doesn't know
+func resolveEnum(r map[string]interface{}, value interface{}) (interface{}, error) {
+	symbol, _ := value.(string)
+	symbols, _ := r["symbols"].([]interface{})
+	for _, known := range symbols {
+		if known == symbol {
+			return symbol, nil
+		}
+	}
+
+	if def, ok := r["default"].(string); ok {
+		return def, nil
+	}
+
+	return nil, errors.Errorf("reader enum has no symbol %s", symbol)
+}
+
+// unionBranch splits goavro's {"type": value} union
+// wrapper, nil is the null branch
+func unionBranch(value interface{}) (string, interface{}) {
+	if wrapped, ok := value.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for name, inner := range wrapped {
+			return name, inner
+		}
+	}
+
+	return "null", nil
+}
+
+// avroMatches reports whether data written as w can be
+// read as r, following the specification's rules
+func avroMatches(writer *avroSchema, w interface{}, wns string, reader *avroSchema, r interface{}, rns string) bool {
+	w, wns = writer.deref(w, wns)
+	r, rns = reader.deref(r, rns)
+	wKind, rKind := avroKind(w), avroKind(r)
+
+	if wKind == "union" || rKind == "union" {
+		return true
+	}
+
+	if wKind != rKind {
+		return promotable(wKind, rKind)
+	}
+
+	switch rKind {
+	case "record", "enum", "fixed":
+		writerName := shortName(newAvroNamedType(w.(map[string]interface{}), wns).fullName)
+		readerType := r.(map[string]interface{})
+		if shortName(newAvroNamedType(readerType, rns).fullName) == writerName {
+			return true
+		}
+
+		aliases, _ := readerType["aliases"].([]interface{})
+		for _, alias := range aliases {
+			if alias, ok := alias.(string); ok && shortName(alias) == writerName {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// promotable reports whether the writer's primitive
+// type can be promoted to the reader's
+func promotable(wKind, rKind string) bool {
+	switch wKind {
+	case "int":
+		return rKind == "long" || rKind == "float" || rKind == "double"
+	case "long":
+		return rKind == "float" || rKind == "double"
+	case "float":
+		return rKind == "double"
+	case "string":
+		return rKind == "bytes"
+	case "bytes":
+		return rKind == "string"
+	}
+
+	return false
+}
+
+// promote converts a primitive to the reader's type
+func promote(wKind, rKind string, value interface{}) interface{} {
+	if wKind == rKind {
+		return value
+	}
+
+	switch v := value.(type) {
+	case int32:
+		switch rKind {
+		case "long":
+			return int64(v)
+		case "float":
+			return float32(v)
+		case "double":
+			return float64(v)
+		}
+	case int64:
+		switch rKind {
+		case "float":
+			return float32(v)
+		case "double":
+			return float64(v)
+		}
+	case float32:
+		return float64(v)
+	case string:
+		return []byte(v)
+	case []byte:
+		return string(v)
+	}
+
+	return value
+}
+
+// avroDefault converts a field default from its JSON
+// form to what goavro would have decoded
+func avroDefault(s *avroSchema, schema interface{}, namespace string, def interface{}) (interface{}, error) {
+	schema, namespace = s.deref(schema, namespace)
+
+	switch avroKind(schema) {
+	case "union":
+		// Defaults of unions are for the first branch
+		branches := schema.([]interface{})
+		if len(branches) == 0 {
+			return nil, errors.New("empty union")
+		}
+		name := s.typeName(branches[0], namespace)
+		if name == "null" {
+			return nil, nil
+		}
+		value, err := avroDefault(s, branches[0], namespace, def)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{name: value}, nil
+	case "null":
+		return nil, nil
+	case "int":
+		n, ok := def.(float64)
+		if !ok {
+			return nil, errors.Errorf("%v is not an int", def)
+		}
+		return int32(n), nil
+	case "long":
+		n, ok := def.(float64)
+		if !ok {
+			return nil, errors.Errorf("%v is not a long", def)
+		}
+		return int64(n), nil
+	case "float":
+		n, ok := def.(float64)
+		if !ok {
+			return nil, errors.Errorf("%v is not a float", def)
+		}
+		return float32(n), nil
+	case "bytes", "fixed":
+		// Bytes defaults are strings of code points 0-255
+		str, ok := def.(string)
+		if !ok {
+			return nil, errors.Errorf("%v is not a bytes string", def)
+		}
+		raw := make([]byte, 0, len(str))
+		for _, r := range str {
+			raw = append(raw, byte(r))
+		}
+		return raw, nil
+	case "array":
+		list, ok := def.([]interface{})
+		if !ok {
+			return nil, errors.Errorf("%v is not an array", def)
+		}
+		values := make([]interface{}, len(list))
+		for i, item := range list {
+			value, err := avroDefault(s, schema.(map[string]interface{})["items"], namespace, item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case "map":
+		items, ok := def.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("%v is not a map", def)
+		}
+		values := make(map[string]interface{}, len(items))
+		for key, item := range items {
+			value, err := avroDefault(s, schema.(map[string]interface{})["values"], namespace, item)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	case "record":
+		items, ok := def.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("%v is not a record", def)
+		}
+		record := schema.(map[string]interface{})
+		childNamespace := newAvroNamedType(record, namespace).namespace
+		values := make(map[string]interface{})
+		fields, _ := record["fields"].([]interface{})
+		for _, field := range fields {
+			field, ok := field.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := field["name"].(string)
+			item, ok := items[name]
+			if !ok {
+				if item, ok = field["default"]; !ok {
+					return nil, errors.Errorf("default is missing field %s", name)
+				}
+			}
+			value, err := avroDefault(s, field["type"], childNamespace, item)
+			if err != nil {
+				return nil, err
+			}
+			values[name] = value
+		}
+		return values, nil
+	}
+
+	// boolean, double, string and enum
+	// defaults are used as they are
+	return def, nil
+}
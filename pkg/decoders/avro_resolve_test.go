@@ -0,0 +1,193 @@
+package decoders_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/linkedin/goavro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	writerV1Schema = `{
+    "type": "record", "name": "User", "namespace": "com.example.v1",
+    "fields": [
+        {"name": "id", "type": "int"},
+        {"name": "name", "type": "string"},
+        {"name": "legacy", "type": "string"},
+        {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["ACTIVE", "BANNED", "DELETED"]}},
+        {"name": "score", "type": ["null", "float"]}
+    ]
+}`
+	writerV2Schema = `{
+    "type": "record", "name": "User", "namespace": "com.example.v1",
+    "fields": [
+        {"name": "id", "type": "int"},
+        {"name": "name", "type": "string"},
+        {"name": "legacy", "type": "string"},
+        {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["ACTIVE", "BANNED", "DELETED"]}},
+        {"name": "score", "type": ["null", "float"]},
+        {"name": "email", "type": "string"}
+    ]
+}`
+	readerSchema = `{
+    "type": "record", "name": "User", "namespace": "com.example.v2",
+    "fields": [
+        {"name": "id", "type": "long"},
+        {"name": "fullName", "type": "string", "aliases": ["name"]},
+        {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["ACTIVE", "BANNED"], "default": "ACTIVE"}},
+        {"name": "score", "type": ["null", "double"]},
+        {"name": "email", "type": ["null", "string"], "default": null},
+        {"name": "age", "type": "int", "default": 7}
+    ]
+}`
+)
+
+// writeSchemas writes the schemas to a temporary
+// directory and returns its path
+func writeSchemas(t *testing.T, schemas map[string]string) string {
+	dir, err := ioutil.TempDir("", "avro")
+	require.Nil(t, err)
+
+	for name, schema := range schemas {
+		require.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(schema), 0644))
+	}
+
+	return dir
+}
+
+func encodeUser(t *testing.T, schema string, user map[string]interface{}) []byte {
+	codec, err := goavro.NewCodec(schema)
+	require.Nil(t, err)
+
+	msg, err := codec.BinaryFromNative(nil, user)
+	require.Nil(t, err)
+
+	return msg
+}
+
+func TestDecodeWithReaderSchema(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"writer.avsc": writerV1Schema,
+		"reader.avsc": readerSchema,
+	})
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{
+		WriterSchemas: filepath.Join(dir, "writer.avsc"),
+	}
+	require.Nil(t, decoder.ValidateSchemas(filepath.Join(dir, "reader.avsc")))
+
+	decoded, err := decoder.Decode(encodeUser(t, writerV1Schema, map[string]interface{}{
+		"id":     int32(42),
+		"name":   "Ada",
+		"legacy": "dropped",
+		"status": "DELETED",
+		"score":  goavro.Union("float", float32(1.5)),
+	}))
+
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id":       int64(42),
+		"fullName": "Ada",
+		"status":   "ACTIVE",
+		"score":    map[string]interface{}{"double": float64(1.5)},
+		"email":    nil,
+		"age":      int32(7),
+	}, decoded)
+}
+
+func TestDecodeWithWriterSchemaDirectory(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"a_v1.avsc": writerV1Schema,
+		"b_v2.avsc": writerV2Schema,
+	})
+	defer os.RemoveAll(dir)
+	readerDir := writeSchemas(t, map[string]string{
+		"reader.avsc": readerSchema,
+	})
+	defer os.RemoveAll(readerDir)
+
+	decoder := &decoders.AvroDecoder{
+		WriterSchemas: dir,
+		Options:       decoders.AvroOptions{UnwrapUnions: true},
+	}
+	require.Nil(t, decoder.ValidateSchemas(filepath.Join(readerDir, "reader.avsc")))
+
+	decoded, err := decoder.Decode(encodeUser(t, writerV2Schema, map[string]interface{}{
+		"id":     int32(1),
+		"name":   "Grace",
+		"legacy": "dropped",
+		"status": "BANNED",
+		"score":  nil,
+		"email":  "grace@example.com",
+	}))
+
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id":       int64(1),
+		"fullName": "Grace",
+		"status":   "BANNED",
+		"score":    nil,
+		"email":    "grace@example.com",
+		"age":      int32(7),
+	}, decoded)
+
+	_, err = decoder.Decode([]byte{0x02})
+	assert.Equal(t, decoders.ErrNoMatchingSchema, err)
+}
+
+func TestDecodeWithAmbiguousWriterSchemas(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"a_v1.avsc": `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "long"}, {"name": "b", "type": "long"}]}`,
+		"b_v2.avsc": `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "long"}, {"name": "c", "type": "long"}]}`,
+	})
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{WriterSchemas: dir}
+	require.Nil(t, decoder.ValidateSchemas(`{"type": "record", "name": "R", "fields": [{"name": "a", "type": "long"}]}`))
+
+	// Both writers read two longs
+	decoded, err := decoder.Decode([]byte{0x02, 0x04})
+	assert.Nil(t, decoded)
+	assert.Equal(t, decoders.ErrAmbiguousSchema, err)
+}
+
+func TestDecodeWithIncompatibleReaderSchema(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"writer.avsc": writerV1Schema,
+		"reader.avsc": `{"type": "record", "name": "User", "fields": [{"name": "required", "type": "string"}]}`,
+	})
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{
+		WriterSchemas: filepath.Join(dir, "writer.avsc"),
+	}
+	require.Nil(t, decoder.ValidateSchemas(filepath.Join(dir, "reader.avsc")))
+
+	_, err := decoder.Decode(encodeUser(t, writerV1Schema, map[string]interface{}{
+		"id":     int32(1),
+		"name":   "Ada",
+		"legacy": "",
+		"status": "ACTIVE",
+		"score":  nil,
+	}))
+
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "reader field required has no default")
+}
+
+func TestValidateSchemasEmptyWriterDirectory(t *testing.T) {
+	dir := writeSchemas(t, nil)
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{
+		WriterSchemas: dir,
+	}
+
+	assert.Equal(t, decoders.ErrNoSchemaFiles, decoder.ValidateSchemas(pathToTestSchema))
+}
@@ -0,0 +1,304 @@
+package decoders
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ErrParsingSchemaWrapper wraps errors returned while parsing schema JSON
+	ErrParsingSchemaWrapper = "error parsing schema %s"
+	// inlineSchemaOrigin names inline schemas in errors
+	inlineSchemaOrigin = "inline schema"
+)
+
+// avroPrimitives can't be redefined or referenced as named types
+var avroPrimitives = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"int":     true,
+	"long":    true,
+	"float":   true,
+	"double":  true,
+	"bytes":   true,
+	"string":  true,
+}
+
+// avroSource is a single schema read from a file,
+// a protocol or passed inline
+type avroSource struct {
+	origin    string
+	text      string
+	schema    interface{}
+	namespace string
+}
+
+// avroProtocol is the part of an .avpr file holding schemas
+type avroProtocol struct {
+	Namespace string        `json:"namespace"`
+	Types     []interface{} `json:"types"`
+}
+
+// loadAvroCodecs creates a codec for every schema passed.
+// schemas is either inline JSON or comma separated .avsc and
+// .avpr files and directories of them. Named types defined
+// in one schema can be referenced by any other.
+func loadAvroCodecs(schemas string) ([]*avroCodec, error) {
+	sources, err := readAvroSources(schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index every named type so references
+	// across files can be resolved
+	index := &avroSchema{
+		names: make(map[string]*avroNamedType),
+	}
+	for _, source := range sources {
+		index.collect(source.schema, source.namespace)
+	}
+
+	codecs := make([]*avroCodec, 0, len(sources))
+	for _, source := range sources {
+		text, err := source.selfContained(index)
+		if err != nil {
+			return nil, errors.Wrapf(err, ErrCreatingCodecWrapper, source.origin)
+		}
+
+		codec, err := newAvroCodec(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, ErrCreatingCodecWrapper, source.origin)
+		}
+		codecs = append(codecs, codec)
+	}
+
+	return codecs, nil
+}
+
+// readAvroSources reads every schema passed
+func readAvroSources(schemas string) ([]*avroSource, error) {
+	trimmed := strings.TrimSpace(schemas)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, `"`) {
+		source, err := newAvroSource(inlineSchemaOrigin, trimmed)
+		if err != nil {
+			return nil, err
+		}
+		return []*avroSource{source}, nil
+	}
+
+	var sources []*avroSource
+	for _, schema := range strings.Split(schemas, ",") {
+		paths, err := avroSchemaFiles(strings.TrimSpace(schema))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			schemaBytes, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, ErrReadingSchemaWrapper, path)
+			}
+
+			if strings.HasSuffix(path, ".avpr") {
+				protocolSources, err := newProtocolSources(path, schemaBytes)
+				if err != nil {
+					return nil, err
+				}
+				sources = append(sources, protocolSources...)
+				continue
+			}
+
+			source, err := newAvroSource(path, string(schemaBytes))
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, source)
+		}
+	}
+
+	return sources, nil
+}
+
+func newAvroSource(origin, text string) (*avroSource, error) {
+	source := &avroSource{
+		origin: origin,
+		text:   text,
+	}
+
+	if err := json.Unmarshal([]byte(text), &source.schema); err != nil {
+		return nil, errors.Wrapf(err, ErrParsingSchemaWrapper, origin)
+	}
+
+	return source, nil
+}
+
+// newProtocolSources returns a source for every type
+// defined in a protocol, in the protocol's namespace
+func newProtocolSources(path string, protocolBytes []byte) ([]*avroSource, error) {
+	var protocol avroProtocol
+	if err := json.Unmarshal(protocolBytes, &protocol); err != nil {
+		return nil, errors.Wrapf(err, ErrParsingSchemaWrapper, path)
+	}
+
+	if len(protocol.Types) == 0 {
+		return nil, ErrNoSchemaFiles
+	}
+
+	sources := make([]*avroSource, len(protocol.Types))
+	for i, schema := range protocol.Types {
+		sources[i] = &avroSource{
+			origin:    path,
+			schema:    schema,
+			namespace: protocol.Namespace,
+		}
+	}
+
+	return sources, nil
+}
+
+// avroSchemaFiles returns path if it's an .avsc or .avpr
+// file, or the files in it sorted by name if it's a directory
+func avroSchemaFiles(path string) ([]string, error) {
+	if strings.HasSuffix(path, ".avsc") || strings.HasSuffix(path, ".avpr") {
+		return []string{path}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return nil, ErrInvalidSchema
+	}
+
+	var paths []string
+	for _, pattern := range []string{"*.avsc", "*.avpr"} {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, errors.Wrapf(err, ErrReadingSchemaWrapper, path)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return nil, ErrNoSchemaFiles
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// selfContained returns the schema's JSON with the first
+// reference to every named type defined elsewhere replaced
+// by its definition, since goavro only knows about types
+// defined in the schema it's given
+func (s *avroSource) selfContained(index *avroSchema) (string, error) {
+	// Types defined in the source itself are never inlined
+	local := &avroSchema{
+		names: make(map[string]*avroNamedType),
+	}
+	local.collect(s.schema, s.namespace)
+
+	inliner := &avroInliner{
+		index:   index,
+		local:   local,
+		defined: make(map[string]bool),
+	}
+	schema := inliner.inline(s.schema, s.namespace)
+
+	if !inliner.changed && s.text != "" && s.namespace == "" {
+		return s.text, nil
+	}
+
+	// Types from a protocol are given its namespace
+	if named, ok := schema.(map[string]interface{}); ok && s.namespace != "" {
+		if _, ok := named["namespace"]; !ok {
+			if kind := avroKind(named); kind == "record" || kind == "enum" || kind == "fixed" {
+				named = copyAvroMap(named)
+				named["namespace"] = s.namespace
+				schema = named
+			}
+		}
+	}
+
+	text, err := json.Marshal(schema)
+	return string(text), err
+}
+
+// avroInliner copies a schema, inlining definitions
+// of named types from other schemas
+type avroInliner struct {
+	index   *avroSchema
+	local   *avroSchema
+	defined map[string]bool
+	changed bool
+}
+
+func (i *avroInliner) inline(schema interface{}, namespace string) interface{} {
+	switch v := schema.(type) {
+	case string:
+		if avroPrimitives[v] || i.local.lookup(v, namespace) != nil {
+			return v
+		}
+
+		named := i.index.lookup(v, namespace)
+		if named == nil || i.defined[named.fullName] {
+			return v
+		}
+
+		// Define the type here, under its full
+		// name so its namespace is kept
+		i.changed = true
+		definition := copyAvroMap(named.schema)
+		definition["name"] = named.fullName
+		delete(definition, "namespace")
+		return i.inline(definition, namespace)
+	case []interface{}:
+		branches := make([]interface{}, len(v))
+		for j, branch := range v {
+			branches[j] = i.inline(branch, namespace)
+		}
+		return branches
+	case map[string]interface{}:
+		definition := copyAvroMap(v)
+		switch avroKind(v) {
+		case "record", "enum", "fixed":
+			named := newAvroNamedType(v, namespace)
+			i.defined[named.fullName] = true
+			if fields, ok := v["fields"].([]interface{}); ok {
+				copied := make([]interface{}, len(fields))
+				for j, field := range fields {
+					if field, ok := field.(map[string]interface{}); ok {
+						field = copyAvroMap(field)
+						field["type"] = i.inline(field["type"], named.namespace)
+						copied[j] = field
+					} else {
+						copied[j] = field
+					}
+				}
+				definition["fields"] = copied
+			}
+		case "array":
+			definition["items"] = i.inline(v["items"], namespace)
+		case "map":
+			definition["values"] = i.inline(v["values"], namespace)
+		default:
+			definition["type"] = i.inline(v["type"], namespace)
+		}
+		return definition
+	}
+
+	return schema
+}
+
+// copyAvroMap makes a shallow copy of a schema object
+func copyAvroMap(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+
+	return copied
+}
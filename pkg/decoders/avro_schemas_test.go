@@ -0,0 +1,110 @@
+package decoders_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Sorted before the file defining Address
+	orderSchema = `{
+    "type": "record", "name": "Order", "namespace": "com.example.orders",
+    "fields": [
+        {"name": "id", "type": "long"},
+        {"name": "shipTo", "type": "com.example.Address"},
+        {"name": "origin", "type": "com.example.Country"}
+    ]
+}`
+	addressSchema = `{
+    "type": "record", "name": "Address", "namespace": "com.example",
+    "fields": [
+        {"name": "city", "type": "string"},
+        {"name": "country", "type": {"type": "enum", "name": "Country", "symbols": ["NL", "US"]}}
+    ]
+}`
+	testProtocol = `{
+    "protocol": "Shop", "namespace": "com.example.shop",
+    "types": [
+        {"type": "enum", "name": "Kind", "symbols": ["BOOK", "GAME"]},
+        {"type": "record", "name": "Product", "fields": [
+            {"name": "sku", "type": "string"},
+            {"name": "kind", "type": "Kind"}
+        ]}
+    ],
+    "messages": {}
+}`
+)
+
+func TestValidateSchemasInline(t *testing.T) {
+	decoder := &decoders.AvroDecoder{}
+	require.Nil(t, decoder.ValidateSchemas(` {"type": "record", "name": "Event", "fields": [{"name": "id", "type": "long"}]}`))
+
+	decoded, err := decoder.Decode([]byte{0x54})
+
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"id": int64(42)}, decoded)
+}
+
+func TestValidateSchemasCrossFileReferences(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"a_order.avsc":   orderSchema,
+		"b_address.avsc": addressSchema,
+	})
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{}
+	require.Nil(t, decoder.ValidateSchemas(dir))
+
+	// id 1, city "Utrecht", country NL, origin US
+	msg := []byte{0x02, 0x0e, 'U', 't', 'r', 'e', 'c', 'h', 't', 0x00, 0x02}
+	decoded, err := decoder.Decode(msg)
+
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id": int64(1),
+		"shipTo": map[string]interface{}{
+			"city":    "Utrecht",
+			"country": "NL",
+		},
+		"origin": "US",
+	}, decoded)
+}
+
+func TestValidateSchemasProtocol(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"shop.avpr": testProtocol,
+	})
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{}
+	require.Nil(t, decoder.ValidateSchemas(filepath.Join(dir, "shop.avpr")))
+
+	// Kind can't read the message, so Product
+	// is the schema that matches
+	decoded, err := decoder.Decode([]byte{0x04, 'a', '1', 0x02})
+
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"sku":  "a1",
+		"kind": "GAME",
+	}, decoded)
+}
+
+func TestValidateSchemasUnknownReference(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"order.avsc": orderSchema,
+	})
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{}
+	err := decoder.ValidateSchemas(dir)
+
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "order.avsc")
+	assert.Contains(t, err.Error(), "com.example.Address")
+}
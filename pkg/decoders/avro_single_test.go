@@ -0,0 +1,90 @@
+package decoders_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// CRC-64-AVRO fingerprints of the parsing canonical
+	// forms, "int" is from the Avro specification's tests
+	intFingerprint   uint64 = 0x7275d51a3f395c8f
+	eventFingerprint uint64 = 0x03d6d45f47449a7e
+	eventSchema             = `{"type": "record", "name": "Event", "doc": "ignored", "fields": [{"name": "id", "type": "long"}]}`
+)
+
+// singleObject frames data in the Avro single-object encoding
+func singleObject(fingerprint uint64, data ...byte) []byte {
+	msg := []byte{0xc3, 0x01, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint64(msg[2:], fingerprint)
+	return append(msg, data...)
+}
+
+func TestDecodeSingleObjectEncoding(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"event.avsc": eventSchema,
+		"int.avsc":   `"int"`,
+	})
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{}
+	require.Nil(t, decoder.ValidateSchemas(dir+","+pathToStringSchema))
+
+	decoded, err := decoder.Decode(singleObject(eventFingerprint, 0x54))
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"id": int64(42)}, decoded)
+
+	decoded, err = decoder.Decode(singleObject(intFingerprint, 0x54))
+	require.Nil(t, err)
+	assert.Equal(t, int32(42), decoded)
+}
+
+func TestDecodeSingleObjectUnknownFingerprint(t *testing.T) {
+	decoder := &decoders.AvroDecoder{}
+	require.Nil(t, decoder.ValidateSchemas(`{"type": "record", "name": "A", "fields": [{"name": "a", "type": "int"}]}`))
+
+	// The header mustn't be read as part of the message
+	decoded, err := decoder.Decode(singleObject(0x0123456789abcdef, 0x54))
+	assert.Nil(t, decoded)
+	require.NotNil(t, err)
+	assert.Equal(t, "unknown schema fingerprint 0123456789abcdef", err.Error())
+}
+
+func TestDecodeMultipleSchemasWithoutFingerprint(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"event.avsc": eventSchema,
+	})
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{}
+	require.Nil(t, decoder.ValidateSchemas(pathToStringSchema+","+filepath.Join(dir, "event.avsc")))
+
+	// Too short for a string of length 3 and too
+	// long for an Event, so neither schema matches
+	decoded, err := decoder.Decode([]byte{0x06, 'h', 'i'})
+	require.NotNil(t, err)
+	assert.Equal(t, decoders.ErrNoMatchingSchema, err)
+
+	decoded, err = decoder.Decode([]byte{0x04, 'h', 'i'})
+	require.Nil(t, err)
+	assert.Equal(t, "hi", decoded)
+
+	decoded, err = decoder.Decode([]byte{0x54})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"id": int64(42)}, decoded)
+}
+
+func TestValidateSchemasEmptyDirectory(t *testing.T) {
+	dir := writeSchemas(t, nil)
+	defer os.RemoveAll(dir)
+
+	decoder := &decoders.AvroDecoder{}
+
+	assert.Equal(t, decoders.ErrNoSchemaFiles, decoder.ValidateSchemas(dir))
+}
@@ -23,12 +23,13 @@ var (
 
 const (
 	invalidExtension       = "test.json"
-	errInvalidExtensionMsg = "invalid schema, schemas must be .avsc files"
+	errInvalidExtensionMsg = "invalid schema, schemas must be .avsc or .avpr files, directories of them or inline JSON"
 	fakePath               = "fake_path.avsc"
 	errReadingSchemaMsg    = "error reading schema fake_path.avsc: open fake_path.avsc: no such file or directory"
 	pathToInvalidSchema    = "../../etc/tests/invalid_schema.avsc"
 	errInvalidSchemaMsg    = "error creating codec for schema ../../etc/tests/invalid_schema.avsc: Record \"com.example.FullName\" field 3 ought to be valid Avro named type: unknown type name: \"notAType\""
 	pathToTestSchema       = "../../etc/tests/test_schema.avsc"
+	pathToStringSchema     = "../../etc/tests/string_schema.avsc"
 	errDecodingMsg         = "error decoding message: cannot decode binary record \"com.example.FullName\" field \"firstName\": cannot decode binary string: cannot decode binary bytes: short buffer"
 )
 
@@ -149,6 +150,22 @@ func TestDecodeSuccessNoConverter(t *testing.T) {
 	assert.True(t, eq)
 }
 
+func TestDecodeNonRecordSchema(t *testing.T) {
+	codec, err := goavro.NewCodec(`"string"`)
+	require.Nil(t, err)
+
+	binary, err := codec.BinaryFromNative(nil, "user-1")
+	require.Nil(t, err)
+
+	decoder := &decoders.AvroDecoder{}
+	err = decoder.ValidateSchemas(pathToStringSchema)
+	require.Nil(t, err)
+
+	decoded, err := decoder.Decode(binary)
+	require.Nil(t, err)
+	assert.Equal(t, "user-1", decoded)
+}
+
 func (t *testConverter) ConvertFields(record map[string]interface{}) error {
 	return errConvertingFailed
 }
@@ -0,0 +1,354 @@
+package decoders
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ExecProtocolVersion is the version of the exec decoder
+	// protocol sent in the handshake
+	ExecProtocolVersion = 1
+	// DefaultExecTimeout is used when ExecDecoder.Timeout isn't set
+	DefaultExecTimeout = 30 * time.Second
+
+	// ErrStartingExecWrapper wraps errors returned while starting the decoder process
+	ErrStartingExecWrapper = "error starting decoder process %s"
+	// ErrExecCrashedWrapper wraps errors returned when the decoder process
+	// stops responding, it is restarted on the next call
+	ErrExecCrashedWrapper = "decoder process %s crashed and will be restarted"
+	// ErrExecTimeoutWrapper wraps the command and timeout of a decoder
+	// process that didn't answer a call in time, it's killed and
+	// restarted on the next call
+	ErrExecTimeoutWrapper = "decoder process %s didn't respond within %s and will be restarted"
+)
+
+var (
+	// ErrNoExecCommand denotes that the exec decoder was used without a command
+	ErrNoExecCommand = errors.New("a decoder command is required")
+	// ErrExecProtocolVersion denotes that the decoder process
+	// answered the handshake with an unsupported version
+	ErrExecProtocolVersion = errors.New("decoder process doesn't support protocol version 1")
+	// ErrUnterminatedQuote denotes that a command passed to
+	// SplitCommand has a quote that isn't closed or ends with
+	// a backslash
+	ErrUnterminatedQuote = errors.New("command has an unterminated quote or a trailing backslash")
+)
+
+// ExecDecoder implements the decoder interface by running
+// a separate decoder process, so decoders can be written in
+// any language and built separately from this program.
+//
+// Requests are written to the process' stdin and responses
+// read from its stdout, one JSON object per line. Every
+// request has a "method" and gets exactly one response,
+// a response with a non empty "error" fails the call:
+//
+//	{"method":"handshake","version":1}        -> {"version":1}
+//	{"method":"validateSchemas","schemas":""} -> {}
+//	{"method":"decode","record":{...}}        -> {"value":...}
+//
+// The record is parser.Record marshalled to JSON, so the key
+// and value are base64 encoded. Anything the process writes
+// to stderr is passed through. If the process exits, or
+// doesn't answer a call within Timeout, it is started again
+// on the next call, replaying the handshake and schema
+// validation.
+type ExecDecoder struct {
+	Command string
+	Args    []string
+	// Timeout bounds every call to the process,
+	// defaults to DefaultExecTimeout
+	Timeout time.Duration
+
+	lock      sync.Mutex
+	schemas   string
+	validated bool
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    *bufio.Reader
+}
+
+// execRequest is a single line written to the decoder process
+type execRequest struct {
+	Method  string         `json:"method"`
+	Version int            `json:"version,omitempty"`
+	Schemas *string        `json:"schemas,omitempty"`
+	Record  *parser.Record `json:"record,omitempty"`
+}
+
+// execResponse is a single line read from the decoder process
+type execResponse struct {
+	Version int             `json:"version"`
+	Value   json.RawMessage `json:"value"`
+	Error   string          `json:"error"`
+}
+
+// ValidateSchemas starts the decoder process and
+// asks it to validate the schemas
+func (e *ExecDecoder) ValidateSchemas(schemas string) error {
+	if e.Command == "" {
+		return ErrNoExecCommand
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.schemas = schemas
+	e.validated = true
+	if e.cmd != nil {
+		_, err := e.call(&execRequest{Method: "validateSchemas", Schemas: &e.schemas})
+		return err
+	}
+
+	return e.start()
+}
+
+// Decode sends a record holding only msg to the decoder process
+func (e *ExecDecoder) Decode(msg []byte) (interface{}, error) {
+	return e.DecodeRecord(&parser.Record{Value: msg})
+}
+
+// DecodeRecord sends the whole record to the decoder process
+func (e *ExecDecoder) DecodeRecord(record *parser.Record) (interface{}, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.cmd == nil {
+		if err := e.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := e.call(&execRequest{Method: "decode", Record: record})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Value) == 0 {
+		return nil, nil
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(response.Value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Close stops the decoder process by closing its stdin
+func (e *ExecDecoder) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.cmd == nil {
+		return nil
+	}
+
+	e.stdin.Close()
+	err := e.cmd.Wait()
+	e.cmd = nil
+	return err
+}
+
+// start runs the decoder process and sets it up, it's
+// killed if the handshake or schema validation fails
+func (e *ExecDecoder) start() error {
+	cmd := exec.Command(e.Command, e.Args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return errors.Wrapf(err, ErrStartingExecWrapper, e.Command)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrapf(err, ErrStartingExecWrapper, e.Command)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrapf(err, ErrStartingExecWrapper, e.Command)
+	}
+
+	e.cmd = cmd
+	e.stdin = stdin
+	e.stdout = bufio.NewReader(stdout)
+
+	// A process that can't be used isn't left running
+	if err := e.setup(); err != nil {
+		if e.cmd != nil {
+			e.kill()
+		}
+		return err
+	}
+
+	return nil
+}
+
+// setup performs the handshake and validates
+// the schemas if they've been passed before
+func (e *ExecDecoder) setup() error {
+	response, err := e.call(&execRequest{Method: "handshake", Version: ExecProtocolVersion})
+	if err != nil {
+		return errors.Wrapf(err, ErrStartingExecWrapper, e.Command)
+	}
+
+	if response.Version != ExecProtocolVersion {
+		return ErrExecProtocolVersion
+	}
+
+	if e.validated {
+		if _, err := e.call(&execRequest{Method: "validateSchemas", Schemas: &e.schemas}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// call writes a request and reads its response. If the
+// process can't be talked to, or takes longer than Timeout,
+// it's killed so the next call starts a new one.
+func (e *ExecDecoder) call(request *execRequest) (*execResponse, error) {
+	line, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Killing the process closes its pipes,
+	// which ends the exchange if it timed out
+	type result struct {
+		line []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func(stdin io.Writer, stdout *bufio.Reader) {
+		if _, err := stdin.Write(append(line, '\n')); err != nil {
+			done <- result{err: err}
+			return
+		}
+		line, err := stdout.ReadBytes('\n')
+		done <- result{line: line, err: err}
+	}(e.stdin, e.stdout)
+
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		line, err = r.line, r.err
+	case <-timer.C:
+		e.kill()
+		return nil, errors.Errorf(ErrExecTimeoutWrapper, e.Command, timeout)
+	}
+	if err != nil {
+		return nil, e.crashed(err)
+	}
+
+	response := &execResponse{}
+	if err := json.Unmarshal(line, response); err != nil {
+		return nil, e.crashed(errors.Wrap(err, "invalid response"))
+	}
+
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+
+	return response, nil
+}
+
+// crashed cleans up after a process that stopped
+// responding and reports how it exited
+func (e *ExecDecoder) crashed(err error) error {
+	if exitErr := e.kill(); exitErr != nil && err == io.EOF {
+		err = exitErr
+	}
+
+	return errors.Wrapf(err, ErrExecCrashedWrapper, e.Command)
+}
+
+// kill stops the process and waits for it to exit
+func (e *ExecDecoder) kill() error {
+	e.stdin.Close()
+	e.cmd.Process.Kill()
+	err := e.cmd.Wait()
+	e.cmd = nil
+
+	if _, ok := err.(*exec.ExitError); ok {
+		return err
+	}
+
+	return nil
+}
+
+// SplitCommand splits command into words the way a shell
+// would, without expanding anything. Words are separated by
+// whitespace, single quotes keep everything up to the next
+// single quote, double quotes keep everything but a backslash
+// escaping " or \, and outside quotes a backslash escapes any
+// character, e.g. '/opt/my plugins/dec' --name "a b".
+func SplitCommand(command string) ([]string, error) {
+	var (
+		words   []string
+		word    []rune
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range command {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				word = append(word, '\\')
+			}
+			word = append(word, r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word = append(word, r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, string(word))
+				word, inWord = nil, false
+			}
+		default:
+			word = append(word, r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, ErrUnterminatedQuote
+	}
+	if inWord {
+		words = append(words, string(word))
+	}
+
+	return words, nil
+}
@@ -0,0 +1,200 @@
+package decoders_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	execHelperArg = "exec-decoder-helper"
+	// execPIDFileEnv names a file the decoder
+	// process writes its PID to
+	execPIDFileEnv = "EXEC_DECODER_HELPER_PID_FILE"
+)
+
+// newExecDecoder runs this test binary as the decoder
+// process, see TestExecHelperProcess
+func newExecDecoder(version int) *decoders.ExecDecoder {
+	return &decoders.ExecDecoder{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestExecHelperProcess", "--", execHelperArg, fmt.Sprint(version)},
+	}
+}
+
+// TestExecHelperProcess isn't a real test, it's the decoder
+// process started by the exec decoder tests. It answers
+// decode calls with the record's value and headers and exits
+// when asked to decode "crash" or hangs on "hang".
+func TestExecHelperProcess(t *testing.T) {
+	args := os.Args
+	if len(args) < 2 || args[len(args)-2] != execHelperArg {
+		return
+	}
+	version := args[len(args)-1]
+
+	if path := os.Getenv(execPIDFileEnv); path != "" {
+		ioutil.WriteFile(path, []byte(fmt.Sprint(os.Getpid())), 0644)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var request struct {
+			Method  string
+			Schemas string
+			Record  parser.Record
+		}
+		json.Unmarshal(scanner.Bytes(), &request)
+
+		switch request.Method {
+		case "handshake":
+			fmt.Printf(`{"version":%s}`+"\n", version)
+		case "validateSchemas":
+			if request.Schemas == "invalid" {
+				fmt.Println(`{"error":"invalid schemas"}`)
+			} else {
+				fmt.Println(`{}`)
+			}
+		case "decode":
+			if string(request.Record.Value) == "hang" {
+				time.Sleep(time.Hour)
+			}
+			if string(request.Record.Value) == "crash" {
+				fmt.Fprintln(os.Stderr, "crashing on purpose")
+				os.Exit(3)
+			}
+			contentType, _ := request.Record.HeaderValue("content-type")
+			response, _ := json.Marshal(map[string]interface{}{
+				"value": map[string]interface{}{
+					"value":       string(request.Record.Value),
+					"contentType": string(contentType),
+					"offset":      request.Record.Offset,
+				},
+			})
+			fmt.Println(string(response))
+		default:
+			fmt.Println(`{"error":"unknown method"}`)
+		}
+	}
+	os.Exit(0)
+}
+
+func TestExecDecodeRecord(t *testing.T) {
+	decoder := newExecDecoder(decoders.ExecProtocolVersion)
+	defer decoder.Close()
+
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	decoded, err := decoder.DecodeRecord(&parser.Record{
+		Offset:  12,
+		Value:   []byte("hello"),
+		Headers: []parser.Header{{Key: "content-type", Value: []byte("text/plain")}},
+	})
+
+	require.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"value":       "hello",
+		"contentType": "text/plain",
+		"offset":      json.Number("12"),
+	}, decoded)
+}
+
+func TestExecValidateSchemasInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exec")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "pid")
+	os.Setenv(execPIDFileEnv, pidFile)
+	defer os.Unsetenv(execPIDFileEnv)
+
+	decoder := newExecDecoder(decoders.ExecProtocolVersion)
+	defer decoder.Close()
+
+	err = decoder.ValidateSchemas("invalid")
+
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid schemas", err.Error())
+
+	// The process was killed and waited on
+	data, err := ioutil.ReadFile(pidFile)
+	require.Nil(t, err)
+	pid, err := strconv.Atoi(string(data))
+	require.Nil(t, err)
+	assert.Equal(t, syscall.ESRCH, syscall.Kill(pid, 0))
+}
+
+func TestExecNoCommand(t *testing.T) {
+	decoder := &decoders.ExecDecoder{}
+
+	assert.Equal(t, decoders.ErrNoExecCommand, decoder.ValidateSchemas(""))
+}
+
+func TestSplitCommand(t *testing.T) {
+	for command, expected := range map[string][]string{
+		"":                                   nil,
+		"  /path/to/decoder  --verbose ":     {"/path/to/decoder", "--verbose"},
+		`'/opt/my plugins/dec' --name "a b"`: {"/opt/my plugins/dec", "--name", "a b"},
+		`/opt/my\ plugins/dec ''`:            {"/opt/my plugins/dec", ""},
+		`dec "say \"hi\" \n" 'it\'`:          {"dec", `say "hi" \n`, `it\`},
+	} {
+		words, err := decoders.SplitCommand(command)
+		require.Nil(t, err)
+		assert.Equal(t, expected, words, command)
+	}
+
+	for _, command := range []string{`dec 'arg`, `dec "arg`, `dec arg\`} {
+		_, err := decoders.SplitCommand(command)
+		assert.Equal(t, decoders.ErrUnterminatedQuote, err, command)
+	}
+}
+
+func TestExecProtocolVersion(t *testing.T) {
+	decoder := newExecDecoder(2)
+	defer decoder.Close()
+
+	assert.Equal(t, decoders.ErrExecProtocolVersion, decoder.ValidateSchemas(""))
+}
+
+func TestExecRestartsCrashedProcess(t *testing.T) {
+	decoder := newExecDecoder(decoders.ExecProtocolVersion)
+	defer decoder.Close()
+
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	_, err := decoder.Decode([]byte("crash"))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "crashed and will be restarted")
+	assert.Contains(t, err.Error(), "exit status 3")
+
+	decoded, err := decoder.Decode([]byte("hello"))
+	require.Nil(t, err)
+	assert.Equal(t, "hello", decoded.(map[string]interface{})["value"])
+}
+
+func TestExecRestartsHungProcess(t *testing.T) {
+	decoder := newExecDecoder(decoders.ExecProtocolVersion)
+	decoder.Timeout = 100 * time.Millisecond
+	defer decoder.Close()
+
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	_, err := decoder.Decode([]byte("hang"))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "didn't respond within 100ms and will be restarted")
+
+	decoded, err := decoder.Decode([]byte("hello"))
+	require.Nil(t, err)
+	assert.Equal(t, "hello", decoded.(map[string]interface{})["value"])
+}
@@ -1,25 +1,63 @@
 package decoders
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
+	"io/ioutil"
+	"strings"
 
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// ErrInvalidJSONWrapper wraps syntax errors with where
+	// in the message they are
+	ErrInvalidJSONWrapper = "invalid JSON at byte %d (line %d, column %d)"
+	// ErrInvalidJSONSchemaWrapper wraps errors returned
+	// parsing a JSON Schema
+	ErrInvalidJSONSchemaWrapper = "invalid JSON schema %s"
+)
+
 // JSONDecoder implements the Decoder interface
 // and is used to decode Kafka messages to JSON
 type JSONDecoder struct {
-	Log *logrus.Logger
+	Log    *logrus.Logger
+	schema *jsonSchema
 }
 
-// ValidateSchemas returns nil since JSON has no defined
-// schema
+// ValidateSchemas optionally takes a draft-07 JSON Schema,
+// either the path to a file or the schema itself. Messages
+// are then checked against it and any violations are
+// printed alongside them.
 func (j *JSONDecoder) ValidateSchemas(schemas string) error {
+	j.schema = nil
+	if schemas == "" {
+		return nil
+	}
+
+	raw := []byte(schemas)
+	if trimmed := strings.TrimSpace(schemas); !strings.HasPrefix(trimmed, "{") && trimmed != "true" && trimmed != "false" {
+		var err error
+		raw, err = ioutil.ReadFile(schemas)
+		if err != nil {
+			return errors.Wrapf(err, ErrReadingSchemaWrapper, schemas)
+		}
+	}
+
+	schema, err := parseJSONSchema(raw)
+	if err != nil {
+		return errors.Wrapf(err, ErrInvalidJSONSchemaWrapper, schemas)
+	}
+	j.schema = schema
+
 	return nil
 }
 
-// Decode takes a sarama consumermessage
+// Decode checks the message is valid JSON and returns it
+// as is. With a schema, messages that break it are returned
+// as a parser.Invalid listing the violations.
 func (j *JSONDecoder) Decode(msg []byte) (interface{}, error) {
 	j.Log.Infof("Decoding JSON message...")
 	// Any valid JSON is more than 1 byte in length
@@ -27,5 +65,47 @@ func (j *JSONDecoder) Decode(msg []byte) (interface{}, error) {
 		return nil, errors.New("invalid JSON, length < 1")
 	}
 
+	if !json.Valid(msg) {
+		return nil, jsonSyntaxError(msg)
+	}
+
+	if j.schema == nil {
+		return json.RawMessage(msg), nil
+	}
+
+	value, err := decodeJSONNumbers(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if violations := j.schema.validate(value); len(violations) > 0 {
+		return &parser.Invalid{
+			Value:      json.RawMessage(msg),
+			Violations: violations,
+		}, nil
+	}
+
 	return json.RawMessage(msg), nil
 }
+
+// jsonSyntaxError finds where msg stops being valid JSON
+func jsonSyntaxError(msg []byte) error {
+	var value interface{}
+	err := json.Unmarshal(msg, &value)
+	syntaxErr, ok := err.(*json.SyntaxError)
+	if !ok {
+		return err
+	}
+
+	// Offset is the number of bytes read when the error
+	// was found, so the offending byte is the last of them
+	offset := syntaxErr.Offset
+	if offset < 1 {
+		offset = 1
+	}
+	before := msg[:offset-1]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return errors.Wrapf(err, ErrInvalidJSONWrapper, offset, line, column)
+}
@@ -0,0 +1,621 @@
+package decoders
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// jsonSchema validates JSON values against a draft-07
+// JSON Schema. Every keyword except format, $id based
+// references and remote references is checked.
+type jsonSchema struct {
+	root     interface{}
+	patterns map[string]*regexp.Regexp
+	// refs holds the references already compiled,
+	// recursive schemas refer back to themselves
+	refs map[string]bool
+}
+
+// parseJSONSchema parses a schema and compiles its
+// patterns, numbers are kept as json.Number
+func parseJSONSchema(data []byte) (*jsonSchema, error) {
+	root, err := decodeJSONNumbers(data)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &jsonSchema{
+		root:     root,
+		patterns: make(map[string]*regexp.Regexp),
+		refs:     make(map[string]bool),
+	}
+
+	if err := s.compile(root); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// decodeJSONNumbers unmarshals data keeping numbers exact
+func decodeJSONNumbers(data []byte) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// compile checks the shape of every subschema, compiles
+// its patterns and those of the schemas it references
+func (s *jsonSchema) compile(schema interface{}) error {
+	if _, ok := schema.(bool); ok {
+		return nil
+	}
+
+	keywords, ok := schema.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("schema must be an object or a boolean, got %s", jsonType(schema))
+	}
+
+	if ref, ok := keywords["$ref"].(string); ok && !s.refs[ref] {
+		target, err := s.resolve(ref)
+		if err != nil {
+			return err
+		}
+
+		// Targets outside the keywords walked
+		// here, like $defs, are only reached this way
+		s.refs[ref] = true
+		if err := s.compile(target); err != nil {
+			return errors.Wrapf(err, "reference %s", ref)
+		}
+	}
+
+	if pattern, ok := keywords["pattern"].(string); ok {
+		if err := s.compilePattern(pattern); err != nil {
+			return err
+		}
+	}
+
+	for _, keyword := range []string{"additionalItems", "additionalProperties", "contains", "else", "if", "not", "propertyNames", "then"} {
+		if sub, ok := keywords[keyword]; ok {
+			if err := s.compile(sub); err != nil {
+				return errors.Wrap(err, keyword)
+			}
+		}
+	}
+
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := keywords[keyword].([]interface{})
+		for _, sub := range list {
+			if err := s.compile(sub); err != nil {
+				return errors.Wrap(err, keyword)
+			}
+		}
+	}
+
+	switch items := keywords["items"].(type) {
+	case []interface{}:
+		for _, sub := range items {
+			if err := s.compile(sub); err != nil {
+				return errors.Wrap(err, "items")
+			}
+		}
+	case nil:
+	default:
+		if err := s.compile(items); err != nil {
+			return errors.Wrap(err, "items")
+		}
+	}
+
+	for _, keyword := range []string{"definitions", "properties", "patternProperties"} {
+		subs, _ := keywords[keyword].(map[string]interface{})
+		for name, sub := range subs {
+			if keyword == "patternProperties" {
+				if err := s.compilePattern(name); err != nil {
+					return err
+				}
+			}
+			if err := s.compile(sub); err != nil {
+				return errors.Wrapf(err, "%s %s", keyword, name)
+			}
+		}
+	}
+
+	dependencies, _ := keywords["dependencies"].(map[string]interface{})
+	for name, dependency := range dependencies {
+		if _, ok := dependency.([]interface{}); ok {
+			continue
+		}
+		if err := s.compile(dependency); err != nil {
+			return errors.Wrapf(err, "dependencies %s", name)
+		}
+	}
+
+	return nil
+}
+
+func (s *jsonSchema) compilePattern(pattern string) error {
+	if _, ok := s.patterns[pattern]; ok {
+		return nil
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return errors.Wrapf(err, "pattern %s", pattern)
+	}
+	s.patterns[pattern] = compiled
+
+	return nil
+}
+
+// resolve follows a reference to a JSON pointer
+// within the schema, like #/definitions/user
+func (s *jsonSchema) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, errors.Errorf("only references within the schema are supported, got %s", ref)
+	}
+
+	pointer, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "reference %s", ref)
+	}
+
+	current := s.root
+	if pointer == "" {
+		return current, nil
+	}
+
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, errors.Errorf("reference %s not found", ref)
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, errors.Errorf("reference %s not found", ref)
+			}
+			current = v[i]
+		default:
+			return nil, errors.Errorf("reference %s not found", ref)
+		}
+	}
+
+	return current, nil
+}
+
+// validate returns a description of every way
+// value breaks the schema, nil if it doesn't
+func (s *jsonSchema) validate(value interface{}) []string {
+	return s.check(s.root, value, "#")
+}
+
+// valid reports whether value matches schema
+func (s *jsonSchema) valid(schema, value interface{}) bool {
+	return len(s.check(schema, value, "")) == 0
+}
+
+func (s *jsonSchema) check(schema, value interface{}, path string) []string {
+	var violations []string
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if allowed, ok := schema.(bool); ok {
+		if !allowed {
+			fail("no value is allowed")
+		}
+		return violations
+	}
+
+	keywords, _ := schema.(map[string]interface{})
+
+	// Other keywords are ignored next to $ref in draft-07
+	if ref, ok := keywords["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		if err != nil {
+			fail("%s", err)
+			return violations
+		}
+		return s.check(target, value, path)
+	}
+
+	if types, ok := keywords["type"]; ok && !matchesType(types, value) {
+		fail("expected %s, got %s", typeList(types), jsonType(value))
+	}
+
+	if enum, ok := keywords["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("%s is not one of %s", jsonText(value), jsonText(enum))
+		}
+	}
+
+	if constant, ok := keywords["const"]; ok && !jsonEqual(constant, value) {
+		fail("%s is not %s", jsonText(value), jsonText(constant))
+	}
+
+	// fail appends to violations, so nested
+	// violations are collected before adding them
+	var nested []string
+	switch v := value.(type) {
+	case json.Number:
+		s.checkNumber(keywords, v, fail)
+	case string:
+		s.checkString(keywords, v, fail)
+	case []interface{}:
+		nested = s.checkArray(keywords, v, path, fail)
+	case map[string]interface{}:
+		nested = s.checkObject(keywords, v, path, fail)
+	}
+	violations = append(violations, nested...)
+
+	if allOf, ok := keywords["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			violations = append(violations, s.check(sub, value, path)...)
+		}
+	}
+
+	if anyOf, ok := keywords["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if s.valid(sub, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("doesn't match any schema in anyOf")
+		}
+	}
+
+	if oneOf, ok := keywords["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if s.valid(sub, value) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("matches %d schemas in oneOf, expected exactly one", matched)
+		}
+	}
+
+	if not, ok := keywords["not"]; ok && s.valid(not, value) {
+		fail("matches the schema in not")
+	}
+
+	if condition, ok := keywords["if"]; ok {
+		if s.valid(condition, value) {
+			if then, ok := keywords["then"]; ok {
+				violations = append(violations, s.check(then, value, path)...)
+			}
+		} else if otherwise, ok := keywords["else"]; ok {
+			violations = append(violations, s.check(otherwise, value, path)...)
+		}
+	}
+
+	return violations
+}
+
+func (s *jsonSchema) checkNumber(keywords map[string]interface{}, value json.Number, fail func(string, ...interface{})) {
+	n, ok := jsonRat(value)
+	if !ok {
+		return
+	}
+
+	if limit, ok := jsonRat(keywords["minimum"]); ok && n.Cmp(limit) < 0 {
+		fail("%s is less than the minimum %s", value, keywords["minimum"])
+	}
+	if limit, ok := jsonRat(keywords["exclusiveMinimum"]); ok && n.Cmp(limit) <= 0 {
+		fail("%s is not greater than the exclusive minimum %s", value, keywords["exclusiveMinimum"])
+	}
+	if limit, ok := jsonRat(keywords["maximum"]); ok && n.Cmp(limit) > 0 {
+		fail("%s is greater than the maximum %s", value, keywords["maximum"])
+	}
+	if limit, ok := jsonRat(keywords["exclusiveMaximum"]); ok && n.Cmp(limit) >= 0 {
+		fail("%s is not less than the exclusive maximum %s", value, keywords["exclusiveMaximum"])
+	}
+	if divisor, ok := jsonRat(keywords["multipleOf"]); ok && divisor.Sign() != 0 {
+		if !new(big.Rat).Quo(n, divisor).IsInt() {
+			fail("%s is not a multiple of %s", value, keywords["multipleOf"])
+		}
+	}
+}
+
+func (s *jsonSchema) checkString(keywords map[string]interface{}, value string, fail func(string, ...interface{})) {
+	length := utf8.RuneCountInString(value)
+
+	if limit, ok := jsonInt(keywords["minLength"]); ok && length < limit {
+		fail("length %d is less than minLength %d", length, limit)
+	}
+	if limit, ok := jsonInt(keywords["maxLength"]); ok && length > limit {
+		fail("length %d is greater than maxLength %d", length, limit)
+	}
+	if pattern, ok := keywords["pattern"].(string); ok && !s.patterns[pattern].MatchString(value) {
+		fail("%s doesn't match the pattern %s", jsonText(value), pattern)
+	}
+}
+
+func (s *jsonSchema) checkArray(keywords map[string]interface{}, value []interface{}, path string, fail func(string, ...interface{})) []string {
+	var violations []string
+
+	if limit, ok := jsonInt(keywords["minItems"]); ok && len(value) < limit {
+		fail("%d items, expected at least %d", len(value), limit)
+	}
+	if limit, ok := jsonInt(keywords["maxItems"]); ok && len(value) > limit {
+		fail("%d items, expected at most %d", len(value), limit)
+	}
+
+	if unique, _ := keywords["uniqueItems"].(bool); unique {
+	duplicates:
+		for i := range value {
+			for j := i + 1; j < len(value); j++ {
+				if jsonEqual(value[i], value[j]) {
+					fail("items %d and %d are equal", i, j)
+					break duplicates
+				}
+			}
+		}
+	}
+
+	switch items := keywords["items"].(type) {
+	case []interface{}:
+		for i, item := range value {
+			itemPath := path + "/" + strconv.Itoa(i)
+			if i < len(items) {
+				violations = append(violations, s.check(items[i], item, itemPath)...)
+			} else if additional, ok := keywords["additionalItems"]; ok {
+				violations = append(violations, s.check(additional, item, itemPath)...)
+			}
+		}
+	case nil:
+	default:
+		for i, item := range value {
+			violations = append(violations, s.check(items, item, path+"/"+strconv.Itoa(i))...)
+		}
+	}
+
+	if contains, ok := keywords["contains"]; ok {
+		found := false
+		for _, item := range value {
+			if s.valid(contains, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("no item matches the schema in contains")
+		}
+	}
+
+	return violations
+}
+
+func (s *jsonSchema) checkObject(keywords map[string]interface{}, value map[string]interface{}, path string, fail func(string, ...interface{})) []string {
+	var violations []string
+
+	if limit, ok := jsonInt(keywords["minProperties"]); ok && len(value) < limit {
+		fail("%d properties, expected at least %d", len(value), limit)
+	}
+	if limit, ok := jsonInt(keywords["maxProperties"]); ok && len(value) > limit {
+		fail("%d properties, expected at most %d", len(value), limit)
+	}
+
+	required, _ := keywords["required"].([]interface{})
+	for _, name := range required {
+		if name, ok := name.(string); ok {
+			if _, ok := value[name]; !ok {
+				fail("missing required property %s", name)
+			}
+		}
+	}
+
+	// Walk properties in order so violations are stable
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	properties, _ := keywords["properties"].(map[string]interface{})
+	patternProperties, _ := keywords["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := keywords["additionalProperties"]
+	propertyNames, hasPropertyNames := keywords["propertyNames"]
+	dependencies, _ := keywords["dependencies"].(map[string]interface{})
+
+	for _, name := range names {
+		propertyPath := path + "/" + strings.Replace(strings.Replace(name, "~", "~0", -1), "/", "~1", -1)
+		item := value[name]
+
+		matched := false
+		if sub, ok := properties[name]; ok {
+			matched = true
+			violations = append(violations, s.check(sub, item, propertyPath)...)
+		}
+		for pattern, sub := range patternProperties {
+			if s.patterns[pattern].MatchString(name) {
+				matched = true
+				violations = append(violations, s.check(sub, item, propertyPath)...)
+			}
+		}
+		if !matched && hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				fail("property %s isn't allowed", name)
+			} else {
+				violations = append(violations, s.check(additional, item, propertyPath)...)
+			}
+		}
+
+		if hasPropertyNames {
+			violations = append(violations, s.check(propertyNames, name, propertyPath)...)
+		}
+
+		switch dependency := dependencies[name].(type) {
+		case []interface{}:
+			for _, needed := range dependency {
+				if needed, ok := needed.(string); ok {
+					if _, ok := value[needed]; !ok {
+						fail("property %s requires property %s", name, needed)
+					}
+				}
+			}
+		case nil:
+		default:
+			violations = append(violations, s.check(dependency, value, path)...)
+		}
+	}
+
+	return violations
+}
+
+// jsonType is the JSON Schema type name of value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if n, ok := jsonRat(v); ok && n.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+// matchesType checks the type keyword, which is
+// either a type name or a list of them
+func matchesType(types, value interface{}) bool {
+	names, ok := types.([]interface{})
+	if !ok {
+		names = []interface{}{types}
+	}
+
+	actual := jsonType(value)
+	for _, name := range names {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func typeList(types interface{}) string {
+	names, ok := types.([]interface{})
+	if !ok {
+		return fmt.Sprint(types)
+	}
+
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = fmt.Sprint(name)
+	}
+
+	return strings.Join(list, " or ")
+}
+
+// jsonEqual compares JSON values, numbers are
+// equal when their values are
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		m, ok1 := jsonRat(x)
+		n, ok2 := jsonRat(y)
+		return ok1 && ok2 && m.Cmp(n) == 0
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a == b
+}
+
+// jsonRat reads a json.Number exactly
+func jsonRat(value interface{}) (*big.Rat, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return nil, false
+	}
+
+	return new(big.Rat).SetString(n.String())
+}
+
+func jsonInt(value interface{}) (int, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, false
+	}
+
+	i, err := n.Int64()
+	return int(i), err == nil
+}
+
+// jsonText prints a value in violations
+func jsonText(value interface{}) string {
+	marshalled, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(marshalled)
+}
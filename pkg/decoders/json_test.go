@@ -0,0 +1,171 @@
+package decoders_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const userJSONSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["id", "name"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 1, "pattern": "^[A-Z]"},
+		"email": {"type": ["string", "null"]},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {
+			"type": "array",
+			"items": {"type": "string"},
+			"uniqueItems": true,
+			"maxItems": 3
+		},
+		"address": {"$ref": "#/definitions/address"}
+	},
+	"definitions": {
+		"address": {
+			"type": "object",
+			"required": ["city"],
+			"properties": {
+				"city": {"type": "string"},
+				"zip": {"type": "string", "pattern": "^[0-9]{5}$"}
+			}
+		}
+	}
+}`
+
+func newJSONDecoder(t *testing.T, schemas string) *decoders.JSONDecoder {
+	log, _ := test.NewNullLogger()
+	decoder := &decoders.JSONDecoder{
+		Log: log,
+	}
+	require.Nil(t, decoder.ValidateSchemas(schemas))
+	return decoder
+}
+
+func TestJSONDecode(t *testing.T) {
+	decoder := newJSONDecoder(t, "")
+
+	decoded, err := decoder.Decode([]byte(`{"id": 1}`))
+	require.Nil(t, err)
+	assert.Equal(t, json.RawMessage(`{"id": 1}`), decoded)
+}
+
+func TestJSONDecodeSyntaxError(t *testing.T) {
+	decoder := newJSONDecoder(t, "")
+
+	_, err := decoder.Decode([]byte{})
+	assert.EqualError(t, err, "invalid JSON, length < 1")
+
+	_, err = decoder.Decode([]byte("{\"id\": 1,\n  \"name\": x}"))
+	assert.EqualError(t, err, "invalid JSON at byte 21 (line 2, column 11): invalid character 'x' looking for beginning of value")
+
+	_, err = decoder.Decode([]byte(`{"id": 1} {}`))
+	assert.EqualError(t, err, "invalid JSON at byte 11 (line 1, column 11): invalid character '{' after top-level value")
+
+	_, err = decoder.Decode([]byte(`{"id": `))
+	assert.Contains(t, err.Error(), "invalid JSON at byte 7 (line 1, column 7)")
+}
+
+func TestJSONDecodeSchema(t *testing.T) {
+	decoder := newJSONDecoder(t, userJSONSchema)
+
+	valid := `{"id": 1, "name": "Ann", "email": null, "tags": ["a"], "address": {"city": "Oslo", "zip": "01234"}}`
+	decoded, err := decoder.Decode([]byte(valid))
+	require.Nil(t, err)
+	assert.Equal(t, json.RawMessage(valid), decoded)
+
+	invalid := `{"id": 0, "name": "ann", "role": "root", "tags": ["a", "a", 1, "b"], "address": {"zip": "1"}, "extra": true}`
+	decoded, err = decoder.Decode([]byte(invalid))
+	require.Nil(t, err)
+	assert.Equal(t, &parser.Invalid{
+		Value: json.RawMessage(invalid),
+		Violations: []string{
+			"#: property extra isn't allowed",
+			"#/address: missing required property city",
+			`#/address/zip: "1" doesn't match the pattern ^[0-9]{5}$`,
+			"#/id: 0 is less than the minimum 1",
+			`#/name: "ann" doesn't match the pattern ^[A-Z]`,
+			`#/role: "root" is not one of ["admin","user"]`,
+			"#/tags: 4 items, expected at most 3",
+			"#/tags: items 0 and 1 are equal",
+			"#/tags/2: expected string, got integer",
+		},
+	}, decoded)
+}
+
+func TestJSONDecodeSchemaCombinators(t *testing.T) {
+	decoder := newJSONDecoder(t, `{
+		"oneOf": [{"multipleOf": 2}, {"multipleOf": 1.5}],
+		"not": {"const": 9},
+		"if": {"exclusiveMaximum": 0},
+		"then": {"minimum": -10}
+	}`)
+
+	for _, valid := range []string{"4", "1.5", "-4"} {
+		decoded, err := decoder.Decode([]byte(valid))
+		require.Nil(t, err)
+		assert.Equal(t, json.RawMessage(valid), decoded)
+	}
+
+	for value, violations := range map[string][]string{
+		"5":   {"#: matches 0 schemas in oneOf, expected exactly one"},
+		"6":   {"#: matches 2 schemas in oneOf, expected exactly one"},
+		"9.0": {"#: matches the schema in not"},
+		"-14": {"#: -14 is less than the minimum -10"},
+	} {
+		decoded, err := decoder.Decode([]byte(value))
+		require.Nil(t, err)
+		assert.Equal(t, &parser.Invalid{
+			Value:      json.RawMessage(value),
+			Violations: violations,
+		}, decoded, value)
+	}
+}
+
+func TestJSONDecodeSchemaRefPattern(t *testing.T) {
+	// The pattern is only reached through the reference,
+	// and the tree refers back to itself
+	decoder := newJSONDecoder(t, `{
+		"$defs": {
+			"id": {"type": "string", "pattern": "^a"},
+			"tree": {"properties": {"id": {"$ref": "#/$defs/id"}, "children": {"items": {"$ref": "#/$defs/tree"}}}}
+		},
+		"$ref": "#/$defs/tree"
+	}`)
+
+	decoded, err := decoder.Decode([]byte(`{"id": "ab", "children": [{"id": "bcd"}]}`))
+	require.Nil(t, err)
+	assert.Equal(t, &parser.Invalid{
+		Value:      json.RawMessage(`{"id": "ab", "children": [{"id": "bcd"}]}`),
+		Violations: []string{`#/children/0/id: "bcd" doesn't match the pattern ^a`},
+	}, decoded)
+
+	err = decoder.ValidateSchemas(`{"$defs": {"id": {"pattern": "("}}, "$ref": "#/$defs/id"}`)
+	assert.Contains(t, err.Error(), "invalid JSON schema")
+}
+
+func TestJSONValidateSchemas(t *testing.T) {
+	decoder := &decoders.JSONDecoder{}
+
+	err := decoder.ValidateSchemas("missing.json")
+	assert.Contains(t, err.Error(), "error reading schema missing.json")
+
+	err = decoder.ValidateSchemas(`{"pattern": "("}`)
+	assert.Contains(t, err.Error(), "invalid JSON schema")
+
+	err = decoder.ValidateSchemas(`{"$ref": "other.json#/definitions/a"}`)
+	assert.Contains(t, err.Error(), "only references within the schema are supported")
+
+	err = decoder.ValidateSchemas(`{"properties": {"a": 1}}`)
+	assert.Contains(t, err.Error(), "schema must be an object or a boolean")
+
+	assert.Nil(t, decoder.ValidateSchemas("false"))
+}
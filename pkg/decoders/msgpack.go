@@ -1,11 +1,28 @@
 package decoders
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
 	"github.com/vmihailenco/msgpack"
+	"github.com/vmihailenco/msgpack/codes"
 )
 
+// msgPackTimestampExt is the extension type MessagePack
+// reserves for timestamps
+const msgPackTimestampExt = -1
+
 // MsgPackDecoder decodes kafka messages written in
-// MsgPack
+// MsgPack. Any value can be at the top level, map keys
+// that aren't strings are printed as strings, timestamps
+// are printed as times and other extension types as
+// {"ext": type, "data": "hex"}.
 type MsgPackDecoder struct{}
 
 // ValidateSchemas returns nil since schemas are not
@@ -16,11 +33,109 @@ func (m *MsgPackDecoder) ValidateSchemas(schemas string) error {
 
 // Decode returns a decoded MessagePack message
 func (m *MsgPackDecoder) Decode(msg []byte) (interface{}, error) {
-	var decoded map[string]interface{}
-	err := msgpack.Unmarshal(msg, &decoded)
+	// bytes.Reader is read directly by the msgpack decoder,
+	// so extension data can be read from it
+	r := bytes.NewReader(msg)
+	return decodeMsgPack(msgpack.NewDecoder(r), r)
+}
+
+// decodeMsgPack decodes the next value into something
+// json.Marshal can print
+func decodeMsgPack(d *msgpack.Decoder, r io.Reader) (interface{}, error) {
+	c, err := d.PeekCode()
 	if err != nil {
 		return nil, err
 	}
 
-	return decoded, nil
+	switch {
+	case codes.IsFixedMap(c) || c == codes.Map16 || c == codes.Map32:
+		n, err := d.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			key, err := decodeMsgPack(d, r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeMsgPack(d, r)
+			if err != nil {
+				return nil, err
+			}
+			values[msgPackKey(key)] = value
+		}
+		return values, nil
+	case codes.IsFixedArray(c) || c == codes.Array16 || c == codes.Array32:
+		n, err := d.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+
+		list := make([]interface{}, n)
+		for i := range list {
+			if list[i], err = decodeMsgPack(d, r); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case codes.IsExt(c):
+		id, n, err := d.DecodeExtHeader()
+		if err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		if id == msgPackTimestampExt {
+			return msgPackTime(data)
+		}
+		return map[string]interface{}{
+			"ext":  id,
+			"data": hex.EncodeToString(data),
+		}, nil
+	}
+
+	return d.DecodeInterface()
+}
+
+// msgPackKey prints a map key as a string, keys
+// that are lists or maps are printed as JSON
+func msgPackKey(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case []byte:
+		return string(k)
+	case nil:
+		return "null"
+	case map[string]interface{}, []interface{}:
+		if encoded, err := json.Marshal(k); err == nil {
+			return string(encoded)
+		}
+	}
+
+	return fmt.Sprint(key)
+}
+
+// msgPackTime reads the 32, 64 and 96 bit
+// formats of the timestamp extension
+func msgPackTime(data []byte) (time.Time, error) {
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		n := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(n&0x3ffffffff), int64(n>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data)
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	}
+
+	return time.Time{}, errors.Errorf("invalid timestamp extension length %d", len(data))
 }
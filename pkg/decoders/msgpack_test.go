@@ -0,0 +1,86 @@
+package decoders_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack"
+)
+
+func decodeMsgPack(t *testing.T, msg []byte) string {
+	decoder := &decoders.MsgPackDecoder{}
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	decoded, err := decoder.Decode(msg)
+	require.Nil(t, err)
+
+	marshalled, err := json.Marshal(decoded)
+	require.Nil(t, err)
+	return string(marshalled)
+}
+
+func TestMsgPackDecodeMap(t *testing.T) {
+	msg, err := msgpack.Marshal(map[string]interface{}{
+		"name": "test",
+		"ids":  map[int]string{1: "one", 2: "two"},
+		"tags": []interface{}{"a", true, nil, 1.5},
+	})
+	require.Nil(t, err)
+
+	assert.JSONEq(t, `{
+		"name": "test",
+		"ids": {"1": "one", "2": "two"},
+		"tags": ["a", true, null, 1.5]
+	}`, decodeMsgPack(t, msg))
+}
+
+func TestMsgPackDecodeTopLevel(t *testing.T) {
+	msg, err := msgpack.Marshal([]interface{}{1, "two", map[bool]int{true: 3}})
+	require.Nil(t, err)
+	assert.JSONEq(t, `[1, "two", {"true": 3}]`, decodeMsgPack(t, msg))
+
+	msg, err = msgpack.Marshal("scalar")
+	require.Nil(t, err)
+	assert.JSONEq(t, `"scalar"`, decodeMsgPack(t, msg))
+
+	msg, err = msgpack.Marshal(42)
+	require.Nil(t, err)
+	assert.JSONEq(t, `42`, decodeMsgPack(t, msg))
+}
+
+func TestMsgPackDecodeTimestamp(t *testing.T) {
+	times := []time.Time{
+		time.Unix(1500000000, 0),
+		time.Unix(1500000000, 123456789),
+		time.Unix(-1, 500),
+	}
+
+	for _, tm := range times {
+		msg, err := msgpack.Marshal(map[string]interface{}{"at": tm})
+		require.Nil(t, err)
+
+		expected, err := json.Marshal(map[string]interface{}{"at": tm.UTC()})
+		require.Nil(t, err)
+		assert.JSONEq(t, string(expected), decodeMsgPack(t, msg))
+	}
+}
+
+func TestMsgPackDecodeUnknownExt(t *testing.T) {
+	// fixarray of one fixext2 with type 5
+	msg := []byte{0x91, 0xd5, 0x05, 0xab, 0xcd}
+	assert.JSONEq(t, `[{"ext": 5, "data": "abcd"}]`, decodeMsgPack(t, msg))
+
+	// ext8 of 3 bytes with type 100
+	msg = []byte{0xc7, 0x03, 0x64, 0x01, 0x02, 0x03}
+	assert.JSONEq(t, `{"ext": 100, "data": "010203"}`, decodeMsgPack(t, msg))
+}
+
+func TestMsgPackDecodeTruncated(t *testing.T) {
+	decoder := &decoders.MsgPackDecoder{}
+	_, err := decoder.Decode([]byte{0x92, 0x01})
+	assert.NotNil(t, err)
+}
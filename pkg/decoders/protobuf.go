@@ -0,0 +1,642 @@
+package decoders
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// Field types and labels from descriptor.proto
+const (
+	protoTypeDouble   = 1
+	protoTypeFloat    = 2
+	protoTypeInt64    = 3
+	protoTypeUint64   = 4
+	protoTypeInt32    = 5
+	protoTypeFixed64  = 6
+	protoTypeFixed32  = 7
+	protoTypeBool     = 8
+	protoTypeString   = 9
+	protoTypeGroup    = 10
+	protoTypeMessage  = 11
+	protoTypeBytes    = 12
+	protoTypeUint32   = 13
+	protoTypeEnum     = 14
+	protoTypeSfixed32 = 15
+	protoTypeSfixed64 = 16
+	protoTypeSint32   = 17
+	protoTypeSint64   = 18
+
+	protoLabelRepeated = 3
+)
+
+const (
+	// ErrReadingDescriptorWrapper wraps errors returned while reading a descriptor set file
+	ErrReadingDescriptorWrapper = "error reading descriptor set %s"
+	// ErrParsingDescriptorWrapper wraps errors returned while parsing a descriptor set file
+	ErrParsingDescriptorWrapper = "error parsing descriptor set %s"
+)
+
+var (
+	// ErrNoDescriptorSet denotes that the protobuf decoder was used without a descriptor set
+	ErrNoDescriptorSet = errors.New("a descriptor set is required, build one with protoc --include_imports --descriptor_set_out")
+	// ErrNoProtoMessage denotes that the protobuf decoder was used without a message name
+	ErrNoProtoMessage = errors.New("a fully qualified protobuf message name is required")
+)
+
+type (
+	// ProtobufDecoder implements the decoder interface for
+	// protobuf messages described by compiled descriptor sets.
+	// Messages are decoded following the proto3 JSON mapping.
+	ProtobufDecoder struct {
+		// Message is the fully qualified name of
+		// the message type, e.g. example.v1.User
+		Message string
+
+		messages map[string]*protoMessage
+		enums    map[string]*protoEnum
+	}
+
+	protoMessage struct {
+		name     string
+		mapEntry bool
+		fields   map[int32]*protoField
+	}
+
+	protoField struct {
+		name     string
+		jsonName string
+		label    int32
+		kind     int32
+		typeName string
+	}
+
+	protoEnum struct {
+		values map[int32]string
+	}
+)
+
+// ValidateSchemas takes in a comma separated list of descriptor
+// set files, built with protoc --descriptor_set_out, and makes
+// sure the message type is in one of them
+func (p *ProtobufDecoder) ValidateSchemas(schemas string) error {
+	if schemas == "" {
+		return ErrNoDescriptorSet
+	}
+
+	if p.Message == "" {
+		return ErrNoProtoMessage
+	}
+
+	p.messages = make(map[string]*protoMessage)
+	p.enums = make(map[string]*protoEnum)
+
+	for _, path := range strings.Split(schemas, ",") {
+		set, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, ErrReadingDescriptorWrapper, path)
+		}
+
+		err = p.parseDescriptorSet(set)
+		if err != nil {
+			return errors.Wrapf(err, ErrParsingDescriptorWrapper, path)
+		}
+	}
+
+	if _, ok := p.messages[strings.TrimPrefix(p.Message, ".")]; !ok {
+		return errors.Errorf("message %s not found in descriptor sets", p.Message)
+	}
+
+	return nil
+}
+
+// Decode decodes a protobuf message into maps
+// following the proto3 JSON mapping
+func (p *ProtobufDecoder) Decode(msg []byte) (interface{}, error) {
+	message, ok := p.messages[strings.TrimPrefix(p.Message, ".")]
+	if !ok {
+		return nil, errors.Errorf("message %s not found, was ValidateSchemas called yet?", p.Message)
+	}
+
+	decoded, err := p.decodeMessage(message, msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, ErrDecodingMessageWrapper)
+	}
+
+	return decoded, nil
+}
+
+// parseDescriptorSet reads a google.protobuf.FileDescriptorSet
+func (p *ProtobufDecoder) parseDescriptorSet(set []byte) error {
+	return walkWire(set, func(field wireField) error {
+		// repeated FileDescriptorProto file = 1
+		if field.number == 1 && field.wireType == wireBytes {
+			return p.parseFile(field.bytes)
+		}
+		return nil
+	})
+}
+
+// parseFile reads a google.protobuf.FileDescriptorProto
+func (p *ProtobufDecoder) parseFile(file []byte) error {
+	var pkg string
+	var messages, enums [][]byte
+
+	err := walkWire(file, func(field wireField) error {
+		switch field.number {
+		case 2: // package
+			pkg = string(field.bytes)
+		case 4: // message_type
+			messages = append(messages, field.bytes)
+		case 5: // enum_type
+			enums = append(enums, field.bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if err := p.parseMessage(pkg, message); err != nil {
+			return err
+		}
+	}
+
+	for _, enum := range enums {
+		if err := p.parseEnum(pkg, enum); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseMessage reads a google.protobuf.DescriptorProto,
+// scope is the package or enclosing message name
+func (p *ProtobufDecoder) parseMessage(scope string, descriptor []byte) error {
+	message := &protoMessage{
+		fields: make(map[int32]*protoField),
+	}
+	var nested, enums [][]byte
+
+	err := walkWire(descriptor, func(field wireField) error {
+		switch field.number {
+		case 1: // name
+			message.name = qualify(scope, string(field.bytes))
+		case 2: // field
+			f, number, err := parseField(field.bytes)
+			if err != nil {
+				return err
+			}
+			message.fields[number] = f
+		case 3: // nested_type
+			nested = append(nested, field.bytes)
+		case 4: // enum_type
+			enums = append(enums, field.bytes)
+		case 7: // options
+			return walkWire(field.bytes, func(option wireField) error {
+				// bool map_entry = 7
+				if option.number == 7 {
+					message.mapEntry = option.scalar != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.messages[message.name] = message
+
+	for _, n := range nested {
+		if err := p.parseMessage(message.name, n); err != nil {
+			return err
+		}
+	}
+
+	for _, enum := range enums {
+		if err := p.parseEnum(message.name, enum); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseField reads a google.protobuf.FieldDescriptorProto
+func parseField(descriptor []byte) (*protoField, int32, error) {
+	f := &protoField{}
+	var number int32
+
+	err := walkWire(descriptor, func(field wireField) error {
+		switch field.number {
+		case 1: // name
+			f.name = string(field.bytes)
+		case 3: // number
+			number = int32(field.scalar)
+		case 4: // label
+			f.label = int32(field.scalar)
+		case 5: // type
+			f.kind = int32(field.scalar)
+		case 6: // type_name
+			f.typeName = strings.TrimPrefix(string(field.bytes), ".")
+		case 10: // json_name
+			f.jsonName = string(field.bytes)
+		}
+		return nil
+	})
+
+	if f.jsonName == "" {
+		f.jsonName = jsonName(f.name)
+	}
+
+	return f, number, err
+}
+
+// parseEnum reads a google.protobuf.EnumDescriptorProto
+func (p *ProtobufDecoder) parseEnum(scope string, descriptor []byte) error {
+	enum := &protoEnum{
+		values: make(map[int32]string),
+	}
+	var name string
+
+	err := walkWire(descriptor, func(field wireField) error {
+		switch field.number {
+		case 1: // name
+			name = qualify(scope, string(field.bytes))
+		case 2: // value
+			var valueName string
+			var number int32
+			err := walkWire(field.bytes, func(value wireField) error {
+				switch value.number {
+				case 1:
+					valueName = string(value.bytes)
+				case 2:
+					number = int32(value.scalar)
+				}
+				return nil
+			})
+			enum.values[number] = valueName
+			return err
+		}
+		return nil
+	})
+
+	p.enums[name] = enum
+	return err
+}
+
+func (p *ProtobufDecoder) decodeMessage(message *protoMessage, buf []byte) (interface{}, error) {
+	switch message.name {
+	case "google.protobuf.Timestamp":
+		return decodeTimestamp(buf)
+	case "google.protobuf.Duration":
+		return decodeDuration(buf)
+	}
+
+	decoded := make(map[string]interface{})
+	err := walkWire(buf, func(wire wireField) error {
+		field, ok := message.fields[wire.number]
+		if !ok {
+			// Unknown fields are dropped like
+			// the proto3 JSON mapping does
+			return nil
+		}
+
+		if field.label != protoLabelRepeated {
+			value, err := p.decodeValue(field, wire)
+			if err != nil {
+				return err
+			}
+			decoded[field.jsonName] = value
+			return nil
+		}
+
+		return p.decodeRepeated(decoded, field, wire)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.wellKnown(message, decoded)
+}
+
+// decodeRepeated appends the value(s) in wire to the list
+// or map kept for the field in decoded
+func (p *ProtobufDecoder) decodeRepeated(decoded map[string]interface{}, field *protoField, wire wireField) error {
+	if entry, ok := p.messages[field.typeName]; ok && entry.mapEntry && field.kind == protoTypeMessage {
+		m, _ := decoded[field.jsonName].(map[string]interface{})
+		if m == nil {
+			m = make(map[string]interface{})
+			decoded[field.jsonName] = m
+		}
+
+		key, value, err := p.decodeMapEntry(entry, wire.bytes)
+		if err != nil {
+			return err
+		}
+		m[key] = value
+		return nil
+	}
+
+	list, _ := decoded[field.jsonName].([]interface{})
+
+	// Numeric fields may be packed into a
+	// single length delimited field
+	if wire.wireType == wireBytes && isPackable(field.kind) {
+		packed, err := unpack(field.kind, wire.bytes)
+		if err != nil {
+			return err
+		}
+		for _, element := range packed {
+			value, err := p.decodeValue(field, element)
+			if err != nil {
+				return err
+			}
+			list = append(list, value)
+		}
+		decoded[field.jsonName] = list
+		return nil
+	}
+
+	value, err := p.decodeValue(field, wire)
+	if err != nil {
+		return err
+	}
+	decoded[field.jsonName] = append(list, value)
+
+	return nil
+}
+
+func (p *ProtobufDecoder) decodeMapEntry(entry *protoMessage, buf []byte) (string, interface{}, error) {
+	keyField, valueField := entry.fields[1], entry.fields[2]
+	if keyField == nil || valueField == nil {
+		return "", nil, errors.Errorf("invalid map entry %s", entry.name)
+	}
+
+	var key, value interface{}
+	err := walkWire(buf, func(wire wireField) error {
+		var err error
+		switch wire.number {
+		case 1:
+			key, err = p.decodeValue(keyField, wire)
+		case 2:
+			value, err = p.decodeValue(valueField, wire)
+		}
+		return err
+	})
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Missing keys and values take their default,
+	// which is what decoding an empty field gives
+	if key == nil {
+		key, err = p.decodeValue(keyField, wireField{})
+	}
+	if value == nil && err == nil {
+		value, err = p.decodeValue(valueField, wireField{})
+	}
+
+	// Map keys are always strings in JSON
+	return fmt.Sprint(key), value, err
+}
+
+func (p *ProtobufDecoder) decodeValue(field *protoField, wire wireField) (interface{}, error) {
+	switch field.kind {
+	case protoTypeDouble:
+		return jsonFloat(math.Float64frombits(wire.scalar)), nil
+	case protoTypeFloat:
+		return jsonFloat(float64(math.Float32frombits(uint32(wire.scalar)))), nil
+	case protoTypeInt64, protoTypeSfixed64:
+		// 64 bit integers are strings in JSON
+		return strconv.FormatInt(int64(wire.scalar), 10), nil
+	case protoTypeUint64, protoTypeFixed64:
+		return strconv.FormatUint(wire.scalar, 10), nil
+	case protoTypeSint64:
+		return strconv.FormatInt(zigzag(wire.scalar), 10), nil
+	case protoTypeInt32, protoTypeSfixed32:
+		return int32(wire.scalar), nil
+	case protoTypeUint32, protoTypeFixed32:
+		return uint32(wire.scalar), nil
+	case protoTypeSint32:
+		return int32(zigzag(wire.scalar)), nil
+	case protoTypeBool:
+		return wire.scalar != 0, nil
+	case protoTypeString:
+		if !utf8.Valid(wire.bytes) {
+			return nil, errors.Errorf("field %s is not valid UTF-8", field.name)
+		}
+		return string(wire.bytes), nil
+	case protoTypeBytes:
+		return base64.StdEncoding.EncodeToString(wire.bytes), nil
+	case protoTypeEnum:
+		if field.typeName == "google.protobuf.NullValue" {
+			return nil, nil
+		}
+		if enum, ok := p.enums[field.typeName]; ok {
+			if name, ok := enum.values[int32(wire.scalar)]; ok {
+				return name, nil
+			}
+		}
+		// Unknown enum values are written as numbers
+		return int32(wire.scalar), nil
+	case protoTypeMessage:
+		message, ok := p.messages[field.typeName]
+		if !ok {
+			return nil, errors.Errorf("message %s not found in descriptor sets", field.typeName)
+		}
+		return p.decodeMessage(message, wire.bytes)
+	}
+
+	return nil, errors.Errorf("unsupported type %d for field %s", field.kind, field.name)
+}
+
+// wellKnown rewrites the well known wrapper and
+// struct types to their special JSON forms
+func (p *ProtobufDecoder) wellKnown(message *protoMessage, decoded map[string]interface{}) (interface{}, error) {
+	switch message.name {
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue",
+		"google.protobuf.BytesValue":
+		if value, ok := decoded["value"]; ok {
+			return value, nil
+		}
+		// Wrappers holding their default value are
+		// empty on the wire, which decoding an empty
+		// field gives, e.g. 0 or "0" for 64 bit types
+		if field, ok := message.fields[1]; ok {
+			return p.decodeValue(field, wireField{})
+		}
+		return nil, nil
+	case "google.protobuf.Struct":
+		if fields, ok := decoded["fields"]; ok {
+			return fields, nil
+		}
+		return map[string]interface{}{}, nil
+	case "google.protobuf.ListValue":
+		if values, ok := decoded["values"]; ok {
+			return values, nil
+		}
+		return []interface{}{}, nil
+	case "google.protobuf.Value":
+		// Only one of the oneof fields is set
+		for _, value := range decoded {
+			return value, nil
+		}
+		return nil, nil
+	}
+
+	return decoded, nil
+}
+
+func decodeTimestamp(buf []byte) (interface{}, error) {
+	seconds, nanos, err := secondsAndNanos(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return time.Unix(seconds, nanos).UTC().Format(time.RFC3339Nano), nil
+}
+
+func decodeDuration(buf []byte) (interface{}, error) {
+	seconds, nanos, err := secondsAndNanos(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	sign := ""
+	if seconds < 0 || nanos < 0 {
+		sign = "-"
+	}
+	if seconds < 0 {
+		seconds = -seconds
+	}
+	if nanos < 0 {
+		nanos = -nanos
+	}
+
+	if nanos == 0 {
+		return fmt.Sprintf("%s%ds", sign, seconds), nil
+	}
+
+	fraction := strings.TrimRight(fmt.Sprintf("%09d", nanos), "0")
+	return fmt.Sprintf("%s%d.%ss", sign, seconds, fraction), nil
+}
+
+func secondsAndNanos(buf []byte) (int64, int64, error) {
+	var seconds, nanos int64
+	err := walkWire(buf, func(wire wireField) error {
+		switch wire.number {
+		case 1:
+			seconds = int64(wire.scalar)
+		case 2:
+			nanos = int64(int32(wire.scalar))
+		}
+		return nil
+	})
+
+	return seconds, nanos, err
+}
+
+// jsonFloat returns the JSON strings used for
+// values json.Marshal can't encode
+func jsonFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+
+	return f
+}
+
+func isPackable(kind int32) bool {
+	switch kind {
+	case protoTypeString, protoTypeBytes, protoTypeMessage, protoTypeGroup:
+		return false
+	}
+
+	return true
+}
+
+// unpack splits a packed repeated field into
+// one wireField per element
+func unpack(kind int32, buf []byte) ([]wireField, error) {
+	var fields []wireField
+	for len(buf) > 0 {
+		var element wireField
+		switch kind {
+		case protoTypeDouble, protoTypeFixed64, protoTypeSfixed64:
+			if len(buf) < 8 {
+				return nil, ErrTruncatedProto
+			}
+			element.scalar = binary.LittleEndian.Uint64(buf)
+			buf = buf[8:]
+		case protoTypeFloat, protoTypeFixed32, protoTypeSfixed32:
+			if len(buf) < 4 {
+				return nil, ErrTruncatedProto
+			}
+			element.scalar = uint64(binary.LittleEndian.Uint32(buf))
+			buf = buf[4:]
+		default:
+			var n int
+			element.scalar, n = proto.DecodeVarint(buf)
+			if n == 0 {
+				return nil, ErrTruncatedProto
+			}
+			buf = buf[n:]
+		}
+		fields = append(fields, element)
+	}
+
+	return fields, nil
+}
+
+// qualify joins a scope and name the way
+// protoc builds fully qualified names
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+
+	return scope + "." + name
+}
+
+// jsonName is protoc's lowerCamelCase conversion,
+// used when a descriptor has no json_name
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper && r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package decoders
+
+import (
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Nested messages deeper than this are left as bytes
+	maxRawDepth = 64
+)
+
+var (
+	wireTypeNames = map[int]string{
+		wireVarint:     "varint",
+		wireFixed64:    "fixed64",
+		wireBytes:      "bytes",
+		wireStartGroup: "group",
+		wireFixed32:    "fixed32",
+	}
+)
+
+// ProtobufRawDecoder decodes protobuf messages without a
+// schema, like protoc --decode_raw. Every field is printed
+// with its number, wire type and value. Length delimited
+// fields are shown as text when they're printable, as a
+// nested message when they parse as one, and as bytes otherwise.
+type ProtobufRawDecoder struct{}
+
+// ValidateSchemas returns nil since the raw
+// decoder doesn't use schemas
+func (p *ProtobufRawDecoder) ValidateSchemas(schemas string) error {
+	return nil
+}
+
+// Decode returns the list of fields in the message
+func (p *ProtobufRawDecoder) Decode(msg []byte) (interface{}, error) {
+	return decodeRaw(msg, 0)
+}
+
+func decodeRaw(buf []byte, depth int) ([]interface{}, error) {
+	fields := []interface{}{}
+	err := walkWire(buf, func(wire wireField) error {
+		field := map[string]interface{}{
+			"field":    wire.number,
+			"wireType": wireTypeNames[wire.wireType],
+		}
+
+		switch wire.wireType {
+		case wireVarint:
+			field["value"] = wire.scalar
+			// Negative int32 and int64 values are
+			// unreadable as unsigned varints
+			if int64(wire.scalar) < 0 {
+				field["signed"] = int64(wire.scalar)
+			}
+		case wireFixed64:
+			field["value"] = wire.scalar
+			field["double"] = jsonFloat(math.Float64frombits(wire.scalar))
+		case wireFixed32:
+			field["value"] = uint32(wire.scalar)
+			field["float"] = jsonFloat(float64(math.Float32frombits(uint32(wire.scalar))))
+		case wireBytes, wireStartGroup:
+			key, value := decodeRawBytes(wire.bytes, depth, wire.wireType == wireStartGroup)
+			field[key] = value
+		}
+
+		fields = append(fields, field)
+		return nil
+	})
+
+	return fields, err
+}
+
+// decodeRawBytes guesses what a length delimited field holds,
+// returning the key it's printed under and its value
+func decodeRawBytes(buf []byte, depth int, group bool) (string, interface{}) {
+	if !group && isPrintable(buf) {
+		return "string", string(buf)
+	}
+
+	if depth < maxRawDepth {
+		if nested, err := decodeRaw(buf, depth+1); err == nil && (group || len(nested) > 0) {
+			return "message", nested
+		}
+	}
+
+	// []byte is written as base64 by json.Marshal
+	return "bytes", buf
+}
+
+func isPrintable(buf []byte) bool {
+	if !utf8.Valid(buf) {
+		return false
+	}
+
+	for _, r := range string(buf) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,56 @@
+package decoders_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtobufRawDecode(t *testing.T) {
+	msg := (&protoMsg{}).
+		varint(1, 150).
+		str(2, "hello").
+		msg(3, (&protoMsg{}).str(1, "nested").varint(2, 1)).
+		bytes(4, []byte{0xff, 0x00}).
+		fixed64(5, 0x3ff8000000000000).
+		varint(6, 0xffffffffffffffff)
+
+	// Group 7 holding a single varint
+	msg.EncodeVarint(7<<3 | 3)
+	msg.varint(1, 5)
+	msg.EncodeVarint(7<<3 | 4)
+
+	decoder := &decoders.ProtobufRawDecoder{}
+	require.Nil(t, decoder.ValidateSchemas(""))
+
+	decoded, err := decoder.Decode(msg.Bytes())
+	require.Nil(t, err)
+
+	marshalled, err := json.Marshal(decoded)
+	require.Nil(t, err)
+	assert.JSONEq(t, `[
+		{"field": 1, "wireType": "varint", "value": 150},
+		{"field": 2, "wireType": "bytes", "string": "hello"},
+		{"field": 3, "wireType": "bytes", "message": [
+			{"field": 1, "wireType": "bytes", "string": "nested"},
+			{"field": 2, "wireType": "varint", "value": 1}
+		]},
+		{"field": 4, "wireType": "bytes", "bytes": "/wA="},
+		{"field": 5, "wireType": "fixed64", "value": 4609434218613702656, "double": 1.5},
+		{"field": 6, "wireType": "varint", "value": 18446744073709551615, "signed": -1},
+		{"field": 7, "wireType": "group", "message": [
+			{"field": 1, "wireType": "varint", "value": 5}
+		]}
+	]`, string(marshalled))
+}
+
+func TestProtobufRawDecodeTruncated(t *testing.T) {
+	decoder := &decoders.ProtobufRawDecoder{}
+
+	_, err := decoder.Decode([]byte{0x08})
+
+	assert.Equal(t, decoders.ErrTruncatedProto, err)
+}
@@ -0,0 +1,247 @@
+package decoders_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// protoMsg builds protobuf wire format by hand, the
+// descriptor types themselves aren't vendored
+type protoMsg struct {
+	proto.Buffer
+}
+
+func (m *protoMsg) varint(field int, v uint64) *protoMsg {
+	m.EncodeVarint(uint64(field)<<3 | 0)
+	m.EncodeVarint(v)
+	return m
+}
+
+func (m *protoMsg) fixed64(field int, v uint64) *protoMsg {
+	m.EncodeVarint(uint64(field)<<3 | 1)
+	m.EncodeFixed64(v)
+	return m
+}
+
+func (m *protoMsg) bytes(field int, b []byte) *protoMsg {
+	m.EncodeVarint(uint64(field)<<3 | 2)
+	m.EncodeRawBytes(b)
+	return m
+}
+
+func (m *protoMsg) str(field int, s string) *protoMsg {
+	return m.bytes(field, []byte(s))
+}
+
+func (m *protoMsg) msg(field int, nested *protoMsg) *protoMsg {
+	return m.bytes(field, nested.Bytes())
+}
+
+// fieldDescriptor builds a FieldDescriptorProto
+func fieldDescriptor(name string, number, label, kind int, typeName string) *protoMsg {
+	f := (&protoMsg{}).str(1, name).varint(3, uint64(number)).varint(4, uint64(label)).varint(5, uint64(kind))
+	if typeName != "" {
+		f.str(6, typeName)
+	}
+	return f
+}
+
+// testDescriptorSet describes
+//
+//	package example;
+//	message User {
+//	  string first_name = 1;
+//	  int64 id = 2;
+//	  repeated string tags = 3;
+//	  Status status = 4;
+//	  map<string, int32> scores = 5;
+//	  repeated int32 nums = 6;
+//	  double ratio = 7;
+//	  google.protobuf.Timestamp created = 8;
+//	  bytes raw = 9;
+//	}
+//	enum Status { UNKNOWN = 0; ACTIVE = 1; }
+func testDescriptorSet(t *testing.T) string {
+	scoresEntry := (&protoMsg{}).str(1, "ScoresEntry").
+		msg(2, fieldDescriptor("key", 1, 1, 9, "")).
+		msg(2, fieldDescriptor("value", 2, 1, 5, "")).
+		msg(7, (&protoMsg{}).varint(7, 1))
+
+	user := (&protoMsg{}).str(1, "User").
+		msg(2, fieldDescriptor("first_name", 1, 1, 9, "")).
+		msg(2, fieldDescriptor("id", 2, 1, 3, "")).
+		msg(2, fieldDescriptor("tags", 3, 3, 9, "")).
+		msg(2, fieldDescriptor("status", 4, 1, 14, ".example.Status")).
+		msg(2, fieldDescriptor("scores", 5, 3, 11, ".example.User.ScoresEntry")).
+		msg(2, fieldDescriptor("nums", 6, 3, 5, "")).
+		msg(2, fieldDescriptor("ratio", 7, 1, 1, "")).
+		msg(2, fieldDescriptor("created", 8, 1, 11, ".google.protobuf.Timestamp")).
+		msg(2, fieldDescriptor("raw", 9, 1, 12, "")).
+		msg(3, scoresEntry)
+
+	status := (&protoMsg{}).str(1, "Status").
+		msg(2, (&protoMsg{}).str(1, "UNKNOWN").varint(2, 0)).
+		msg(2, (&protoMsg{}).str(1, "ACTIVE").varint(2, 1))
+
+	example := (&protoMsg{}).str(1, "example.proto").str(2, "example").msg(4, user).msg(5, status)
+
+	timestamp := (&protoMsg{}).str(1, "google/protobuf/timestamp.proto").str(2, "google.protobuf").
+		msg(4, (&protoMsg{}).str(1, "Timestamp").
+			msg(2, fieldDescriptor("seconds", 1, 1, 3, "")).
+			msg(2, fieldDescriptor("nanos", 2, 1, 5, "")))
+
+	return writeDescriptorSet(t, (&protoMsg{}).msg(1, example).msg(1, timestamp))
+}
+
+// wrappersDescriptorSet describes
+//
+//	package example;
+//	message Wrapped {
+//	  google.protobuf.DoubleValue d = 1;
+//	  ...one field for every wrapper type
+//	  google.protobuf.Int32Value set = 10;
+//	}
+func wrappersDescriptorSet(t *testing.T) string {
+	wrappers := []struct {
+		name string
+		kind int
+	}{
+		{"DoubleValue", 1}, {"FloatValue", 2}, {"Int64Value", 3},
+		{"UInt64Value", 4}, {"Int32Value", 5}, {"UInt32Value", 13},
+		{"BoolValue", 8}, {"StringValue", 9}, {"BytesValue", 12},
+	}
+
+	wrappersFile := (&protoMsg{}).str(1, "google/protobuf/wrappers.proto").str(2, "google.protobuf")
+	wrapped := (&protoMsg{}).str(1, "Wrapped")
+	for i, wrapper := range wrappers {
+		wrappersFile.msg(4, (&protoMsg{}).str(1, wrapper.name).
+			msg(2, fieldDescriptor("value", 1, 1, wrapper.kind, "")))
+		wrapped.msg(2, fieldDescriptor(wrapper.name, i+1, 1, 11, ".google.protobuf."+wrapper.name))
+	}
+	wrapped.msg(2, fieldDescriptor("set", 10, 1, 11, ".google.protobuf.Int32Value"))
+
+	example := (&protoMsg{}).str(1, "wrapped.proto").str(2, "example").msg(4, wrapped)
+
+	return writeDescriptorSet(t, (&protoMsg{}).msg(1, example).msg(1, wrappersFile))
+}
+
+func writeDescriptorSet(t *testing.T, set *protoMsg) string {
+	file, err := ioutil.TempFile("", "descriptor_set")
+	require.Nil(t, err)
+	defer file.Close()
+	_, err = file.Write(set.Bytes())
+	require.Nil(t, err)
+
+	return file.Name()
+}
+
+func TestProtobufValidateSchemas(t *testing.T) {
+	set := testDescriptorSet(t)
+	defer os.Remove(set)
+
+	decoder := &decoders.ProtobufDecoder{}
+	assert.Equal(t, decoders.ErrNoDescriptorSet, decoder.ValidateSchemas(""))
+	assert.Equal(t, decoders.ErrNoProtoMessage, decoder.ValidateSchemas(set))
+
+	decoder.Message = "example.Missing"
+	err := decoder.ValidateSchemas(set)
+	require.NotNil(t, err)
+	assert.Equal(t, "message example.Missing not found in descriptor sets", err.Error())
+
+	decoder.Message = "fake_path.pb"
+	err = decoder.ValidateSchemas("fake_path.pb")
+	require.NotNil(t, err)
+	assert.Equal(t, "error reading descriptor set fake_path.pb: open fake_path.pb: no such file or directory", err.Error())
+}
+
+func TestProtobufDecode(t *testing.T) {
+	set := testDescriptorSet(t)
+	defer os.Remove(set)
+
+	decoder := &decoders.ProtobufDecoder{Message: ".example.User"}
+	require.Nil(t, decoder.ValidateSchemas(set))
+
+	packed := &protoMsg{}
+	packed.EncodeVarint(1)
+	packed.EncodeVarint(150)
+
+	msg := (&protoMsg{}).
+		str(1, "Ken").
+		varint(2, 1<<40).
+		str(3, "a").
+		str(3, "b").
+		varint(4, 1).
+		msg(5, (&protoMsg{}).str(1, "math").varint(2, 90)).
+		msg(5, (&protoMsg{}).str(1, "art")).
+		bytes(6, packed.Bytes()).
+		fixed64(7, 0x3ff8000000000000).
+		msg(8, (&protoMsg{}).varint(1, 1530453900).varint(2, 500000000)).
+		bytes(9, []byte{0xde, 0xad}).
+		str(99, "unknown fields are dropped")
+
+	decoded, err := decoder.Decode(msg.Bytes())
+	require.Nil(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"firstName": "Ken",
+		"id":        "1099511627776",
+		"tags":      []interface{}{"a", "b"},
+		"status":    "ACTIVE",
+		"scores":    map[string]interface{}{"math": int32(90), "art": int32(0)},
+		"nums":      []interface{}{int32(1), int32(150)},
+		"ratio":     1.5,
+		"created":   "2018-07-01T14:05:00.5Z",
+		"raw":       "3q0=",
+	}, decoded)
+}
+
+func TestProtobufDecodeTruncated(t *testing.T) {
+	set := testDescriptorSet(t)
+	defer os.Remove(set)
+
+	decoder := &decoders.ProtobufDecoder{Message: "example.User"}
+	require.Nil(t, decoder.ValidateSchemas(set))
+
+	decoded, err := decoder.Decode([]byte{0x0a, 0x05, 'K'})
+
+	assert.Nil(t, decoded)
+	require.NotNil(t, err)
+	assert.Equal(t, "error decoding message: truncated protobuf message", err.Error())
+}
+
+func TestProtobufDecodeDefaultWrappers(t *testing.T) {
+	set := wrappersDescriptorSet(t)
+	defer os.Remove(set)
+
+	decoder := &decoders.ProtobufDecoder{Message: "example.Wrapped"}
+	require.Nil(t, decoder.ValidateSchemas(set))
+
+	// Wrappers holding their default value are empty
+	msg := &protoMsg{}
+	for field := 1; field <= 9; field++ {
+		msg.msg(field, &protoMsg{})
+	}
+	msg.msg(10, (&protoMsg{}).varint(1, 7))
+
+	decoded, err := decoder.Decode(msg.Bytes())
+	require.Nil(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"DoubleValue": float64(0),
+		"FloatValue":  float64(0),
+		"Int64Value":  "0",
+		"UInt64Value": "0",
+		"Int32Value":  int32(0),
+		"UInt32Value": uint32(0),
+		"BoolValue":   false,
+		"StringValue": "",
+		"BytesValue":  "",
+		"set":         int32(7),
+	}, decoded)
+}
@@ -0,0 +1,134 @@
+package decoders
+
+import (
+	"encoding/binary"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// Protobuf wire types
+const (
+	wireVarint     = 0
+	wireFixed64    = 1
+	wireBytes      = 2
+	wireStartGroup = 3
+	wireEndGroup   = 4
+	wireFixed32    = 5
+)
+
+var (
+	// ErrTruncatedProto denotes that a protobuf message ended in the middle of a field
+	ErrTruncatedProto = errors.New("truncated protobuf message")
+)
+
+// wireField is a single field read from the protobuf
+// wire format. Varint and fixed fields are stored in
+// scalar, length delimited fields and groups in bytes.
+type wireField struct {
+	number   int32
+	wireType int
+	scalar   uint64
+	bytes    []byte
+}
+
+// walkWire calls fn for every field in buf in the order
+// they were written
+func walkWire(buf []byte, fn func(field wireField) error) error {
+	for len(buf) > 0 {
+		field, n, err := readField(buf)
+		if err != nil {
+			return err
+		}
+		if field.wireType == wireEndGroup {
+			return errors.Errorf("unexpected end group for field %d", field.number)
+		}
+		buf = buf[n:]
+
+		if err := fn(field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readField reads the field at the start of buf and returns
+// it along with the number of bytes it took up. The contents
+// of a group are returned as bytes, without the end group tag.
+func readField(buf []byte) (wireField, int, error) {
+	tag, n := proto.DecodeVarint(buf)
+	if n == 0 {
+		return wireField{}, 0, ErrTruncatedProto
+	}
+
+	field := wireField{
+		number:   int32(tag >> 3),
+		wireType: int(tag & 7),
+	}
+	if field.number <= 0 {
+		return wireField{}, 0, errors.Errorf("invalid protobuf field number %d", field.number)
+	}
+
+	rest := buf[n:]
+	switch field.wireType {
+	case wireVarint:
+		var size int
+		field.scalar, size = proto.DecodeVarint(rest)
+		if size == 0 {
+			return wireField{}, 0, ErrTruncatedProto
+		}
+		n += size
+	case wireFixed64:
+		if len(rest) < 8 {
+			return wireField{}, 0, ErrTruncatedProto
+		}
+		field.scalar = binary.LittleEndian.Uint64(rest)
+		n += 8
+	case wireFixed32:
+		if len(rest) < 4 {
+			return wireField{}, 0, ErrTruncatedProto
+		}
+		field.scalar = uint64(binary.LittleEndian.Uint32(rest))
+		n += 4
+	case wireBytes:
+		length, size := proto.DecodeVarint(rest)
+		if size == 0 || uint64(len(rest)-size) < length {
+			return wireField{}, 0, ErrTruncatedProto
+		}
+		field.bytes = rest[size : size+int(length)]
+		n += size + int(length)
+	case wireStartGroup:
+		// Read fields until the matching end group
+		pos := 0
+		for {
+			if pos >= len(rest) {
+				return wireField{}, 0, ErrTruncatedProto
+			}
+			nested, size, err := readField(rest[pos:])
+			if err != nil {
+				return wireField{}, 0, err
+			}
+			if nested.wireType == wireEndGroup {
+				if nested.number != field.number {
+					return wireField{}, 0, errors.Errorf("mismatched end group for field %d", field.number)
+				}
+				field.bytes = rest[:pos]
+				n += pos + size
+				break
+			}
+			pos += size
+		}
+	case wireEndGroup:
+		// Handled by the caller
+	default:
+		return wireField{}, 0, errors.Errorf("unsupported protobuf wire type %d for field %d", field.wireType, field.number)
+	}
+
+	return field, n, nil
+}
+
+// zigzag decodes sint32 and sint64 values
+func zigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,125 @@
+package decoders
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// Options holds the settings decoder factories
+	// may need, decoders ignore what they don't use
+	Options struct {
+		Log         *logrus.Logger
+		RegistryURL string
+		// RegistryTimeout is passed to AvroRegistryDecoder
+		RegistryTimeout time.Duration
+		ProtoMessage    string
+		Avro            AvroOptions
+		// WriterSchemas is passed to AvroDecoder
+		WriterSchemas string
+	}
+
+	// Factory creates a new decoder from Options
+	Factory func(opts Options) (parser.RecordDecoder, error)
+
+	// Registry maps -type names to decoder factories so
+	// programs embedding the consumer can link in their
+	// own decoders instead of loading plugins
+	Registry struct {
+		lock      sync.RWMutex
+		factories map[string]Factory
+	}
+)
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[string]Factory),
+	}
+}
+
+// NewDefaultRegistry returns a Registry holding
+// every decoder in this package
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("avro", func(opts Options) (parser.RecordDecoder, error) {
+		return parser.FromDecoder(&AvroDecoder{
+			Options:       opts.Avro,
+			WriterSchemas: opts.WriterSchemas,
+		}), nil
+	})
+	r.Register("avro-registry", func(opts Options) (parser.RecordDecoder, error) {
+		return parser.FromDecoder(&AvroRegistryDecoder{
+			URL:     opts.RegistryURL,
+			Timeout: opts.RegistryTimeout,
+			Options: opts.Avro,
+		}), nil
+	})
+	r.Register("json", func(opts Options) (parser.RecordDecoder, error) {
+		return parser.FromDecoder(&JSONDecoder{
+			Log: opts.Log,
+		}), nil
+	})
+	r.Register("msgpack", func(opts Options) (parser.RecordDecoder, error) {
+		return parser.FromDecoder(&MsgPackDecoder{}), nil
+	})
+	r.Register("protobuf", func(opts Options) (parser.RecordDecoder, error) {
+		return parser.FromDecoder(&ProtobufDecoder{
+			Message: opts.ProtoMessage,
+		}), nil
+	})
+	r.Register("protobuf-raw", func(opts Options) (parser.RecordDecoder, error) {
+		return parser.FromDecoder(&ProtobufRawDecoder{}), nil
+	})
+	r.Register("string", func(opts Options) (parser.RecordDecoder, error) {
+		return parser.FromDecoder(&StringDecoder{}), nil
+	})
+
+	return r
+}
+
+// Register makes a decoder available under name. Like
+// database/sql drivers, registering a nil factory or the
+// same name twice panics.
+func (r *Registry) Register(name string, factory Factory) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if factory == nil {
+		panic(fmt.Sprintf("decoders: Register factory for %s is nil", name))
+	}
+
+	if _, ok := r.factories[name]; ok {
+		panic(fmt.Sprintf("decoders: Register called twice for %s", name))
+	}
+
+	r.factories[name] = factory
+}
+
+// Lookup returns the factory registered under name
+func (r *Registry) Lookup(name string) (Factory, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	factory, ok := r.factories[name]
+	return factory, ok
+}
+
+// Names returns the sorted names of all registered decoders
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
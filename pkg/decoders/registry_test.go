@@ -0,0 +1,57 @@
+package decoders_test
+
+import (
+	"testing"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/decoders"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+	registry := decoders.NewDefaultRegistry()
+
+	assert.Equal(t, []string{
+		"avro",
+		"avro-registry",
+		"json",
+		"msgpack",
+		"protobuf",
+		"protobuf-raw",
+		"string",
+	}, registry.Names())
+
+	factory, ok := registry.Lookup("string")
+	require.True(t, ok)
+
+	decoder, err := factory(decoders.Options{})
+	require.Nil(t, err)
+
+	decoded, err := decoder.DecodeRecord(&parser.Record{Value: []byte("hello")})
+	require.Nil(t, err)
+	assert.Equal(t, "hello", decoded)
+}
+
+func TestRegistryRegister(t *testing.T) {
+	registry := decoders.NewRegistry()
+	factory := func(opts decoders.Options) (parser.RecordDecoder, error) {
+		return parser.FromDecoder(&decoders.StringDecoder{}), nil
+	}
+
+	_, ok := registry.Lookup("in-house")
+	assert.False(t, ok)
+
+	registry.Register("in-house", factory)
+
+	_, ok = registry.Lookup("in-house")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"in-house"}, registry.Names())
+
+	assert.Panics(t, func() {
+		registry.Register("in-house", factory)
+	})
+	assert.Panics(t, func() {
+		registry.Register("nil", nil)
+	})
+}
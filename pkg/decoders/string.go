@@ -0,0 +1,16 @@
+package decoders
+
+// StringDecoder decodes kafka messages
+// as plain UTF-8 strings
+type StringDecoder struct{}
+
+// ValidateSchemas returns nil since strings
+// have no schema
+func (s *StringDecoder) ValidateSchemas(schemas string) error {
+	return nil
+}
+
+// Decode returns the message as a string
+func (s *StringDecoder) Decode(msg []byte) (interface{}, error) {
+	return string(msg), nil
+}
@@ -0,0 +1,68 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+)
+
+// CSVFormatter writes one row per envelope with a
+// column for each of the dotted field paths in Fields,
+// e.g. partition, offset or value.user.id
+type CSVFormatter struct {
+	Fields []string
+}
+
+// Header returns the header row, one column per field
+func (c *CSVFormatter) Header() ([]byte, error) {
+	return c.row(c.Fields)
+}
+
+// Format implements parser.Formatter. Missing fields are
+// left empty and nested objects are written as JSON.
+func (c *CSVFormatter) Format(envelope *parser.Envelope) ([]byte, error) {
+	flattened, err := generic(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(c.Fields))
+	for i, field := range c.Fields {
+		columns[i], err = cell(lookup(flattened, field))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.row(columns)
+}
+
+func (c *CSVFormatter) row(columns []string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+func cell(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	}
+
+	marshalled, err := json.Marshal(value)
+	return string(marshalled), err
+}
@@ -0,0 +1,279 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+)
+
+type (
+	// Rotation decides when a FileSink starts a new file.
+	// The zero value writes everything to one file.
+	Rotation struct {
+		// MaxBytes starts a new file once the current
+		// one holds at least this many bytes
+		MaxBytes int64
+		// Interval starts a new file once the current
+		// one has been open this long
+		Interval time.Duration
+	}
+
+	// FileSink writes every envelope to the file at Path.
+	// An existing file is appended to, never truncated, and
+	// only gets a header if it's empty. With a Rotation, files
+	// are numbered instead, e.g. out.000001.ndjson, and a new
+	// one is started before the envelope that goes over the
+	// limits. Files are only rotated when there's something
+	// to write, numbers already taken by earlier runs are skipped.
+	FileSink struct {
+		Path     string
+		Rotation Rotation
+		file     *os.File
+		// current is the path of the file being written,
+		// it's kept while the file is suspended
+		current string
+		size    int64
+		opened  time.Time
+		index   int
+	}
+
+	// SplitSink writes envelopes to one FileSink per value
+	// of Field, a dotted path like the csv output's fields,
+	// e.g. partition or value.user.id. The value is added to
+	// the file name, out.ndjson becomes out-3.ndjson. At
+	// most MaxOpen files are kept open, the least recently
+	// used one is closed and appended to when it's next needed.
+	SplitSink struct {
+		Path     string
+		Field    string
+		Rotation Rotation
+		MaxOpen  int
+		files    map[string]*FileSink
+		// open lists the names of the open
+		// files, the most recently used last
+		open []string
+	}
+)
+
+// DefaultMaxOpenFiles is the MaxOpen of a new SplitSink
+const DefaultMaxOpenFiles = 64
+
+// NewFileSink returns a FileSink writing to path
+func NewFileSink(path string, rotation Rotation) *FileSink {
+	return &FileSink{
+		Path:     path,
+		Rotation: rotation,
+	}
+}
+
+// Writer implements parser.Sink
+func (f *FileSink) Writer(envelope *parser.Envelope) (io.Writer, bool, error) {
+	if f.current != "" && f.full() {
+		if err := f.Close(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if f.current != "" {
+		if f.file == nil {
+			if err := f.resume(); err != nil {
+				return nil, false, err
+			}
+		}
+		return f, false, nil
+	}
+
+	if err := f.open(); err != nil {
+		return nil, false, err
+	}
+
+	return f, f.size == 0, nil
+}
+
+// Write writes to the current file, counting its size
+func (f *FileSink) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file, the next
+// envelope is written to a new one
+func (f *FileSink) Close() error {
+	f.current = ""
+	return f.suspend()
+}
+
+// suspend closes the current file, the next
+// envelope is appended to it
+func (f *FileSink) suspend() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *FileSink) resume() error {
+	var err error
+	f.file, err = os.OpenFile(f.current, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	return err
+}
+
+// full reports whether the current file is over the limits
+func (f *FileSink) full() bool {
+	if f.Rotation.MaxBytes > 0 && f.size >= f.Rotation.MaxBytes {
+		return true
+	}
+
+	return f.Rotation.Interval > 0 && time.Since(f.opened) >= f.Rotation.Interval
+}
+
+func (f *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+
+	var err error
+	if f.Rotation == (Rotation{}) {
+		f.current = f.Path
+		f.file, err = os.OpenFile(f.current, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	} else {
+		for {
+			f.index++
+			f.current = withSuffix(f.Path, fmt.Sprintf(".%06d", f.index))
+			f.file, err = os.OpenFile(f.current, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if !os.IsExist(err) {
+				break
+			}
+		}
+	}
+	if err != nil {
+		f.current = ""
+		return err
+	}
+
+	info, err := f.file.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	f.size = info.Size()
+	f.opened = time.Now()
+	return nil
+}
+
+// NewSplitSink returns a SplitSink writing to files named after path
+func NewSplitSink(path, field string, rotation Rotation) *SplitSink {
+	return &SplitSink{
+		Path:     path,
+		Field:    field,
+		Rotation: rotation,
+		MaxOpen:  DefaultMaxOpenFiles,
+		files:    make(map[string]*FileSink),
+	}
+}
+
+// Writer implements parser.Sink
+func (s *SplitSink) Writer(envelope *parser.Envelope) (io.Writer, bool, error) {
+	name, err := s.name(envelope)
+	if err != nil {
+		return nil, false, err
+	}
+
+	file, ok := s.files[name]
+	if !ok {
+		file = NewFileSink(withSuffix(s.Path, "-"+name), s.Rotation)
+		s.files[name] = file
+	}
+
+	w, header, err := file.Writer(envelope)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := s.used(name); err != nil {
+		return nil, false, err
+	}
+
+	return w, header, nil
+}
+
+// used moves name to the end of the open files,
+// suspending the least recently used ones over MaxOpen
+func (s *SplitSink) used(name string) error {
+	for i, open := range s.open {
+		if open == name {
+			s.open = append(s.open[:i], s.open[i+1:]...)
+			break
+		}
+	}
+	s.open = append(s.open, name)
+
+	for s.MaxOpen > 0 && len(s.open) > s.MaxOpen {
+		oldest := s.open[0]
+		s.open = s.open[1:]
+		if err := s.files[oldest].suspend(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes every file, returning the first error
+func (s *SplitSink) Close() error {
+	var err error
+	for _, file := range s.files {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	s.open = nil
+
+	return err
+}
+
+// name is the value of Field made safe to use in a file name
+func (s *SplitSink) name(envelope *parser.Envelope) (string, error) {
+	if s.Field == "partition" {
+		return strconv.Itoa(int(envelope.Partition)), nil
+	}
+
+	flattened, err := generic(envelope)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := cell(lookup(flattened, s.Field))
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "none", nil
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, value), nil
+}
+
+// withSuffix adds suffix to the file name in path,
+// before the extension
+func withSuffix(path, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + suffix + ext
+}
@@ -0,0 +1,213 @@
+package output_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	cluster "github.com/bsm/sarama-cluster"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/output"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closedConsumer hands out messages that
+// were queued before it was created
+type closedConsumer struct {
+	msgs chan *sarama.ConsumerMessage
+}
+
+func newClosedConsumer(msgs ...*sarama.ConsumerMessage) *closedConsumer {
+	c := &closedConsumer{
+		msgs: make(chan *sarama.ConsumerMessage, len(msgs)),
+	}
+	for _, msg := range msgs {
+		c.msgs <- msg
+	}
+	close(c.msgs)
+
+	return c
+}
+
+func (c *closedConsumer) Messages() <-chan *sarama.ConsumerMessage {
+	return c.msgs
+}
+
+func (c *closedConsumer) Errors() <-chan error {
+	return nil
+}
+
+func (c *closedConsumer) Notifications() <-chan *cluster.Notification {
+	return nil
+}
+
+func (c *closedConsumer) Close() error {
+	return nil
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "output")
+	require.Nil(t, err)
+	return dir
+}
+
+func writeEnvelope(t *testing.T, sink parser.Sink, data string) bool {
+	w, header, err := sink.Writer(testEnvelope())
+	require.Nil(t, err)
+	_, err = w.Write([]byte(data))
+	require.Nil(t, err)
+	return header
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	return string(data)
+}
+
+func TestFileSink(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "nested", "out.ndjson")
+	sink := output.NewFileSink(path, output.Rotation{})
+
+	assert.True(t, writeEnvelope(t, sink, "1\n"))
+	assert.False(t, writeEnvelope(t, sink, "2\n"))
+	require.Nil(t, sink.Close())
+
+	assert.Equal(t, "1\n2\n", readFile(t, path))
+}
+
+func TestFileSinkAppends(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.ndjson")
+	require.Nil(t, ioutil.WriteFile(path, []byte("0\n"), 0644))
+	sink := output.NewFileSink(path, output.Rotation{})
+
+	// The file isn't empty so it has its header already
+	assert.False(t, writeEnvelope(t, sink, "1\n"))
+	require.Nil(t, sink.Close())
+
+	assert.Equal(t, "0\n1\n", readFile(t, path))
+}
+
+func TestFileSinkRotateSize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// Numbers taken by an earlier run are skipped
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "out.000001.ndjson"), []byte("old\n"), 0644))
+
+	sink := output.NewFileSink(filepath.Join(dir, "out.ndjson"), output.Rotation{MaxBytes: 4})
+
+	assert.True(t, writeEnvelope(t, sink, "1\n"))
+	assert.False(t, writeEnvelope(t, sink, "22\n"))
+	assert.True(t, writeEnvelope(t, sink, "3\n"))
+	require.Nil(t, sink.Close())
+
+	assert.Equal(t, "old\n", readFile(t, filepath.Join(dir, "out.000001.ndjson")))
+	assert.Equal(t, "1\n22\n", readFile(t, filepath.Join(dir, "out.000002.ndjson")))
+	assert.Equal(t, "3\n", readFile(t, filepath.Join(dir, "out.000003.ndjson")))
+}
+
+func TestFileSinkRotateInterval(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	sink := output.NewFileSink(filepath.Join(dir, "out"), output.Rotation{Interval: time.Nanosecond})
+
+	assert.True(t, writeEnvelope(t, sink, "1\n"))
+	assert.True(t, writeEnvelope(t, sink, "2\n"))
+	require.Nil(t, sink.Close())
+
+	assert.Equal(t, "1\n", readFile(t, filepath.Join(dir, "out.000001")))
+	assert.Equal(t, "2\n", readFile(t, filepath.Join(dir, "out.000002")))
+}
+
+func TestSplitSink(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	formatter, err := output.New("csv", output.Options{Fields: []string{"offset", "value.user"}})
+	require.Nil(t, err)
+
+	consumer := newClosedConsumer(
+		&sarama.ConsumerMessage{Partition: 0, Offset: 1, Value: []byte(`{"user": "a/b"}`)},
+		&sarama.ConsumerMessage{Partition: 1, Offset: 2, Value: []byte(`{"user": "c"}`)},
+		&sarama.ConsumerMessage{Partition: 0, Offset: 3, Value: []byte(`{"user": "a/b"}`)},
+		&sarama.ConsumerMessage{Partition: 1, Offset: 4, Value: []byte(`{}`)},
+	)
+	log, hook := test.NewNullLogger()
+
+	p, err := parser.New(consumer, "topic", "", rawJSON{}, log,
+		parser.WithFormatter(formatter), parser.WithSink(output.NewSplitSink(filepath.Join(dir, "out.csv"), "value.user", output.Rotation{})))
+	require.Nil(t, err)
+
+	require.Nil(t, p.Run(context.Background()))
+	require.Nil(t, p.Close())
+	assert.Empty(t, hook.AllEntries())
+
+	assert.Equal(t, "offset,value.user\n1,a/b\n3,a/b\n", readFile(t, filepath.Join(dir, "out-a_b.csv")))
+	assert.Equal(t, "offset,value.user\n2,c\n", readFile(t, filepath.Join(dir, "out-c.csv")))
+	assert.Equal(t, "offset,value.user\n4,\n", readFile(t, filepath.Join(dir, "out-none.csv")))
+}
+
+func TestSplitSinkMaxOpen(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	sink := output.NewSplitSink(filepath.Join(dir, "out.ndjson"), "offset", output.Rotation{MaxBytes: 4})
+	sink.MaxOpen = 1
+
+	var headers []bool
+	for _, offset := range []int64{1, 2, 1, 2} {
+		envelope := testEnvelope()
+		envelope.Offset = offset
+		w, header, err := sink.Writer(envelope)
+		require.Nil(t, err)
+		_, err = w.Write([]byte("x\n"))
+		require.Nil(t, err)
+		headers = append(headers, header)
+	}
+	require.Nil(t, sink.Close())
+
+	// Closed files are appended to when they're
+	// needed again, not rotated or given a header
+	assert.Equal(t, []bool{true, true, false, false}, headers)
+	assert.Equal(t, "x\nx\n", readFile(t, filepath.Join(dir, "out-1.000001.ndjson")))
+	assert.Equal(t, "x\nx\n", readFile(t, filepath.Join(dir, "out-2.000001.ndjson")))
+}
+
+func TestSplitSinkPartition(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	sink := output.NewSplitSink(filepath.Join(dir, "out.ndjson"), "partition", output.Rotation{})
+
+	assert.True(t, writeEnvelope(t, sink, "1\n"))
+	assert.False(t, writeEnvelope(t, sink, "2\n"))
+	require.Nil(t, sink.Close())
+
+	assert.Equal(t, "1\n2\n", readFile(t, filepath.Join(dir, "out-1.ndjson")))
+}
+
+// rawJSON passes messages through as JSON
+type rawJSON struct{}
+
+func (rawJSON) ValidateSchemas(schemas string) error {
+	return nil
+}
+
+func (rawJSON) Decode(msg []byte) (interface{}, error) {
+	return json.RawMessage(msg), nil
+}
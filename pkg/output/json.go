@@ -0,0 +1,30 @@
+package output
+
+import (
+	"encoding/json"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+)
+
+// JSONFormatter writes each envelope as a JSON
+// document. Without an Indent it writes one compact
+// document per line (NDJSON).
+type JSONFormatter struct {
+	Indent string
+}
+
+// Format implements parser.Formatter
+func (j *JSONFormatter) Format(envelope *parser.Envelope) ([]byte, error) {
+	var marshalled []byte
+	var err error
+	if j.Indent == "" {
+		marshalled, err = json.Marshal(envelope)
+	} else {
+		marshalled, err = json.MarshalIndent(envelope, "", j.Indent)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(marshalled, '\n'), nil
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNoFields denotes that the csv format was chosen without any fields
+	ErrNoFields = errors.New("the csv format requires at least one field")
+	// ErrNoTemplate denotes that the template format was chosen without a template
+	ErrNoTemplate = errors.New("the template format requires a template")
+	// SupportedFormats lists the names accepted by New
+	SupportedFormats = []string{
+		"json",
+		"ndjson",
+		"yaml",
+		"csv",
+		"template",
+	}
+)
+
+// Options holds the settings used by
+// some of the formatters
+type Options struct {
+	// Fields are the dotted paths written as
+	// CSV columns, e.g. value.user.id
+	Fields []string
+	// Template is a text/template executed
+	// against each parser.Envelope
+	Template string
+}
+
+// New returns the built-in formatter with the given name
+func New(name string, opts Options) (parser.Formatter, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return &JSONFormatter{Indent: "    "}, nil
+	case "ndjson":
+		return &JSONFormatter{}, nil
+	case "yaml":
+		return &YAMLFormatter{}, nil
+	case "csv":
+		if len(opts.Fields) == 0 {
+			return nil, ErrNoFields
+		}
+		return &CSVFormatter{Fields: opts.Fields}, nil
+	case "template":
+		if opts.Template == "" {
+			return nil, ErrNoTemplate
+		}
+		return NewTemplateFormatter(opts.Template)
+	}
+
+	return nil, errors.Errorf("unknown output format %s", name)
+}
+
+// IsSupported reports whether name is a built-in format
+func IsSupported(name string) bool {
+	for _, format := range SupportedFormats {
+		if strings.EqualFold(format, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generic converts any value json.Marshal understands into
+// maps, slices and scalars so formatters can walk it. Numbers
+// are kept as json.Number to avoid losing precision.
+func generic(value interface{}) (interface{}, error) {
+	marshalled, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(marshalled))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	err = decoder.Decode(&decoded)
+	return decoded, err
+}
+
+// lookup follows a dotted path through nested maps,
+// returning nil if any part of it is missing
+func lookup(value interface{}, path string) interface{} {
+	for _, part := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+
+	return value
+}
@@ -0,0 +1,106 @@
+package output_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/output"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEnvelope() *parser.Envelope {
+	return &parser.Envelope{
+		Topic:     "test",
+		Partition: 1,
+		Offset:    42,
+		Key:       "user-1",
+		Timestamp: time.Date(2018, 7, 1, 14, 5, 0, 0, time.UTC),
+		Headers: map[string]string{
+			"content-type": "application/json",
+		},
+		Value: json.RawMessage(`{"user": {"id": 7, "name": "Ken: the author"}, "tags": ["a", "b"], "empty": {}}`),
+	}
+}
+
+func format(t *testing.T, name string, opts output.Options) string {
+	formatter, err := output.New(name, opts)
+	require.Nil(t, err)
+
+	formatted, err := formatter.Format(testEnvelope())
+	require.Nil(t, err)
+
+	return string(formatted)
+}
+
+func TestNDJSON(t *testing.T) {
+	expected := `{"topic":"test","partition":1,"offset":42,"key":"user-1","timestamp":"2018-07-01T14:05:00Z",` +
+		`"blockTimestamp":"0001-01-01T00:00:00Z","headers":{"content-type":"application/json"},` +
+		`"value":{"user":{"id":7,"name":"Ken: the author"},"tags":["a","b"],"empty":{}}}` + "\n"
+
+	assert.Equal(t, expected, format(t, "ndjson", output.Options{}))
+}
+
+func TestYAML(t *testing.T) {
+	expected := `---
+topic: test
+partition: 1
+offset: 42
+key: user-1
+timestamp: "2018-07-01T14:05:00Z"
+blockTimestamp: "0001-01-01T00:00:00Z"
+headers:
+  content-type: application/json
+value:
+  user:
+    id: 7
+    name: "Ken: the author"
+  tags:
+    - a
+    - b
+  empty: {}
+`
+
+	assert.Equal(t, expected, format(t, "yaml", output.Options{}))
+}
+
+func TestCSV(t *testing.T) {
+	opts := output.Options{
+		Fields: []string{"partition", "offset", "value.user.id", "value.user.name", "value.tags", "value.missing"},
+	}
+	formatter, err := output.New("csv", opts)
+	require.Nil(t, err)
+
+	header, ok := formatter.(parser.HeaderFormatter)
+	require.True(t, ok)
+	row, err := header.Header()
+	require.Nil(t, err)
+	assert.Equal(t, "partition,offset,value.user.id,value.user.name,value.tags,value.missing\n", string(row))
+
+	assert.Equal(t, "1,42,7,Ken: the author,\"[\"\"a\"\",\"\"b\"\"]\",\n", format(t, "csv", opts))
+
+	_, err = output.New("csv", output.Options{})
+	assert.Equal(t, output.ErrNoFields, err)
+}
+
+func TestTemplate(t *testing.T) {
+	opts := output.Options{
+		Template: "{{.Partition}}:{{.Offset}} {{.Value.user.id}}",
+	}
+
+	assert.Equal(t, "1:42 7\n", format(t, "template", opts))
+
+	_, err := output.New("template", output.Options{Template: "{{.Broken"})
+	assert.NotNil(t, err)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := output.New("xml", output.Options{})
+
+	require.NotNil(t, err)
+	assert.Equal(t, "unknown output format xml", err.Error())
+	assert.False(t, output.IsSupported("xml"))
+	assert.True(t, output.IsSupported("YAML"))
+}
@@ -0,0 +1,54 @@
+package output
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+)
+
+// TemplateFormatter executes a text/template against each
+// envelope. Key and Value are converted to plain maps first
+// so templates can reach into them, e.g.
+// {{.Partition}}:{{.Offset}} {{.Value.user.id}}
+type TemplateFormatter struct {
+	template *template.Template
+}
+
+// NewTemplateFormatter parses text into a TemplateFormatter
+func NewTemplateFormatter(text string) (*TemplateFormatter, error) {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemplateFormatter{template: tmpl}, nil
+}
+
+// Format implements parser.Formatter, a newline is
+// added unless the template already ends with one
+func (t *TemplateFormatter) Format(envelope *parser.Envelope) ([]byte, error) {
+	data := *envelope
+
+	var err error
+	data.Key, err = generic(envelope.Key)
+	if err != nil {
+		return nil, err
+	}
+	data.Value, err = generic(envelope.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := t.template.Execute(buf, &data); err != nil {
+		return nil, err
+	}
+
+	formatted := buf.Bytes()
+	if len(formatted) == 0 || formatted[len(formatted)-1] != '\n' {
+		formatted = append(formatted, '\n')
+	}
+
+	return formatted, nil
+}
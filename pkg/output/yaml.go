@@ -0,0 +1,182 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+)
+
+var (
+	// Strings matching this are written without quotes,
+	// anything else is written as a double quoted string
+	plainScalar = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_./@ -]*$`)
+	// Plain strings YAML would read as something else
+	reservedScalars = map[string]bool{
+		"true": true, "false": true, "yes": true, "no": true,
+		"on": true, "off": true, "null": true, "y": true, "n": true,
+	}
+)
+
+type (
+	// YAMLFormatter writes each envelope as
+	// its own YAML document
+	YAMLFormatter struct{}
+
+	// yamlMap keeps the order keys were
+	// encoded in, unlike map[string]interface{}
+	yamlMap []yamlEntry
+
+	yamlEntry struct {
+		key   string
+		value interface{}
+	}
+)
+
+// Format implements parser.Formatter
+func (y *YAMLFormatter) Format(envelope *parser.Envelope) ([]byte, error) {
+	marshalled, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(marshalled))
+	decoder.UseNumber()
+	value, err := decodeOrdered(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("---\n")
+	for _, line := range yamlLines(value) {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeOrdered reads the next JSON value from the
+// decoder, using yamlMap for objects
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		m := yamlMap{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yamlEntry{key: key.(string), value: value})
+		}
+		_, err = decoder.Token()
+		return m, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = decoder.Token()
+		return list, err
+	}
+
+	return token, nil
+}
+
+// yamlLines renders value in block style without any
+// indentation, callers indent nested values
+func yamlLines(value interface{}) []string {
+	switch v := value.(type) {
+	case yamlMap:
+		if len(v) == 0 {
+			return []string{"{}"}
+		}
+		var lines []string
+		for _, entry := range v {
+			key := yamlScalar(entry.key)
+			if isCollection(entry.value) {
+				lines = append(lines, key+":")
+				lines = append(lines, indent(yamlLines(entry.value), "  ", "  ")...)
+			} else {
+				lines = append(lines, key+": "+yamlLines(entry.value)[0])
+			}
+		}
+		return lines
+	case []interface{}:
+		if len(v) == 0 {
+			return []string{"[]"}
+		}
+		var lines []string
+		for _, element := range v {
+			lines = append(lines, indent(yamlLines(element), "- ", "  ")...)
+		}
+		return lines
+	}
+
+	return []string{yamlScalar(value)}
+}
+
+// isCollection reports whether value is written
+// on its own lines rather than after its key
+func isCollection(value interface{}) bool {
+	switch v := value.(type) {
+	case yamlMap:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	}
+
+	return false
+}
+
+func indent(lines []string, first, rest string) []string {
+	indented := make([]string, len(lines))
+	for i, line := range lines {
+		if i == 0 {
+			indented[i] = first + line
+		} else {
+			indented[i] = rest + line
+		}
+	}
+
+	return indented
+}
+
+func yamlScalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return v.String()
+	case string:
+		if plainScalar.MatchString(v) && !reservedScalars[strings.ToLower(v)] && !strings.HasSuffix(v, " ") {
+			return v
+		}
+		// JSON strings are valid double quoted YAML
+		quoted, _ := json.Marshal(v)
+		return string(quoted)
+	}
+
+	return ""
+}
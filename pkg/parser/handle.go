@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+type (
+	// Handle is returned by Serve to shut the Parser down
+	Handle struct {
+		parser *Parser
+		cancel context.CancelFunc
+	}
+
+	// Summary counts what the Parser read, it's
+	// complete once the Parser has finished
+	Summary struct {
+		// Messages read from each partition
+		Messages map[int32]int
+		// Errors is the number of messages that
+		// couldn't be decoded
+		Errors int
+		// Bytes is the size of every key and value read
+		Bytes int64
+		// Elapsed is how long the Parser ran for
+		Elapsed time.Duration
+	}
+)
+
+// Stop cancels Run and waits for the message being printed,
+// if any, to finish before closing the Parser, see
+// Parser.Close. Stop can be called more than once, also
+// after the Parser's limits were reached, and returns the
+// same error every time.
+func (h *Handle) Stop() error {
+	h.cancel()
+	<-h.parser.finished
+
+	return h.parser.Close()
+}
+
+// Finished is closed once Run has returned
+func (h *Handle) Finished() <-chan struct{} {
+	return h.parser.finished
+}
+
+// Summary returns what the Parser has read so far, it
+// must only be called once Finished is closed
+func (p *Parser) Summary() Summary {
+	return p.summary
+}
+
+// Total is the number of messages read
+func (s Summary) Total() int {
+	total := 0
+	for _, count := range s.Messages {
+		total += count
+	}
+
+	return total
+}
+
+func (p *Parser) writeSummary(out io.Writer) {
+	s := p.summary
+
+	partitions := make([]int, 0, len(s.Messages))
+	for partition := range s.Messages {
+		partitions = append(partitions, int(partition))
+	}
+	sort.Ints(partitions)
+
+	_, err := fmt.Fprintf(out, "Processed a total of %d messages (%d bytes) in %s, %d could not be decoded\n",
+		s.Total(), s.Bytes, s.Elapsed.Round(time.Millisecond), s.Errors)
+	for _, partition := range partitions {
+		if err != nil {
+			break
+		}
+		_, err = fmt.Fprintf(out, "  partition %d: %d messages\n", partition, s.Messages[int32(partition)])
+	}
+
+	if err != nil {
+		p.log.Errorf("Could not write summary: %s", err.Error())
+	}
+}
@@ -1,10 +1,20 @@
 package parser
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/sarama"
 	cluster "github.com/bsm/sarama-cluster"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,6 +33,13 @@ type (
 		Decode([]byte) (interface{}, error)
 	}
 
+	// Converter changes fields of a decoded value so they're
+	// easier to read, for example parsing a string field that
+	// holds JSON. Converters run in order after any decoder.
+	Converter interface {
+		ConvertFields(record map[string]interface{}) error
+	}
+
 	// Consumer is the interface for a Kafka consumer
 	// By using an interface that matches bsm/sarama-cluster
 	// instead of passing in an instance, testing is made easy
@@ -30,92 +47,800 @@ type (
 		Messages() <-chan *sarama.ConsumerMessage
 		Errors() <-chan error
 		Notifications() <-chan *cluster.Notification
+		Close() error
+	}
+
+	// offsetMarker and offsetCommitter are implemented
+	// by consumers that are part of a group, like
+	// cluster.Consumer
+	offsetMarker interface {
+		MarkOffset(msg *sarama.ConsumerMessage, metadata string)
+	}
+	offsetCommitter interface {
+		CommitOffsets() error
+	}
+
+	// highWaterMarker is implemented by consumers that
+	// report the high water mark of every partition they've
+	// fetched, like cluster.Consumer and PartitionConsumer
+	highWaterMarker interface {
+		HighWaterMarks() map[string]map[int32]int64
+	}
+
+	// Limits bound how much of a topic the Parser
+	// reads before it stops on its own. The zero value
+	// reads until the Parser is told to stop.
+	Limits struct {
+		// MaxMessages stops after printing this many
+		// messages, hidden tombstones and records the
+		// error policy skips don't count. 0 means no limit
+		MaxMessages int
+		// EndOffsets holds the exclusive end offset of
+		// every partition that must be read before stopping.
+		// Messages at or past the end are skipped. A nil map
+		// reads forever, an empty map stops right away.
+		EndOffsets map[int32]int64
+		// Until completes a partition at the first message
+		// written at or after it
+		Until time.Time
+		// Settle completes the partitions whose high water
+		// mark has reached their end offset once no message
+		// has arrived from any partition for this long. The
+		// last offsets of transactional topics are commit
+		// markers, which are never delivered. Defaults to
+		// DefaultSettle, only consumers reporting high water
+		// marks use it.
+		Settle time.Duration
+	}
+
+	// Envelope is written to the output for every
+	// message, it carries the decoded value along with
+	// where the message came from. Error and Raw are set
+	// instead of Value for records that couldn't be decoded
+	// when the error policy is ErrorPolicyEmit.
+	Envelope struct {
+		Topic          string            `json:"topic"`
+		Partition      int32             `json:"partition"`
+		Offset         int64             `json:"offset"`
+		Key            interface{}       `json:"key"`
+		Timestamp      time.Time         `json:"timestamp"`
+		BlockTimestamp time.Time         `json:"blockTimestamp"`
+		Headers        map[string]string `json:"headers,omitempty"`
+		Value          interface{}       `json:"value"`
+		Tombstone      bool              `json:"tombstone,omitempty"`
+		Violations     []string          `json:"violations,omitempty"`
+		Error          string            `json:"error,omitempty"`
+		Raw            *Raw              `json:"raw,omitempty"`
+	}
+
+	// Raw holds the value of a record that
+	// couldn't be decoded in readable forms
+	Raw struct {
+		Hex    string `json:"hex"`
+		Base64 string `json:"base64"`
+	}
+
+	// DeadLetter is written to the dead letter output,
+	// one JSON document per line, for every record that
+	// couldn't be decoded. Key, value and header values
+	// are base64 encoded.
+	DeadLetter struct {
+		Record
+		Error string `json:"error"`
 	}
 
+	// ErrorPolicy decides what happens to records
+	// that can't be decoded
+	ErrorPolicy string
+
+	// Formatter turns an Envelope into the bytes written
+	// to the output for it, including any trailing newline
+	Formatter interface {
+		Format(envelope *Envelope) ([]byte, error)
+	}
+
+	// HeaderFormatter is implemented by Formatters that
+	// write something once before the first message, like
+	// the header row of a CSV file
+	HeaderFormatter interface {
+		Formatter
+		Header() ([]byte, error)
+	}
+
+	// Option configures optional Parser behavior
+	Option func(*Parser)
+
 	// Parser consumes from a Kafka topic, calls
 	// message decoders, and prints the message to
 	// the console in JSON format
 	Parser struct {
-		consumer Consumer
-		topic    string
-		decoder  Decoder
-		log      *logrus.Logger
+		consumer       Consumer
+		topic          string
+		decoder        RecordDecoder
+		keyDecoder     RecordDecoder
+		keySchemas     string
+		log            *logrus.Logger
+		limits         Limits
+		sink           Sink
+		formatter      Formatter
+		converters     []Converter
+		hideTombstones bool
+		onError        ErrorPolicy
+		deadLetters    io.Writer
+		err            error
+		summary        Summary
+		printed        int
+		summaryOut     io.Writer
+		running        int32
+		finished       chan struct{}
+		closeOnce      sync.Once
+		closeErr       error
 	}
 )
 
+const (
+	// ErrorPolicySkip logs the error and moves on
+	// to the next record, the default
+	ErrorPolicySkip ErrorPolicy = "skip"
+	// ErrorPolicyStop stops the Parser, Err returns why
+	ErrorPolicyStop ErrorPolicy = "stop"
+	// ErrorPolicyEmit prints the record with its raw
+	// value and the error in place of the decoded value
+	ErrorPolicyEmit ErrorPolicy = "emit"
+)
+
+// DefaultSettle is used when Limits.Settle isn't set
+const DefaultSettle = time.Second
+
+// ErrAlreadyRunning is returned by Run when
+// it's called more than once
+var ErrAlreadyRunning = errors.New("parser is already running")
+
+// ErrorPolicies lists every ErrorPolicy
+var ErrorPolicies = []ErrorPolicy{ErrorPolicySkip, ErrorPolicyStop, ErrorPolicyEmit}
+
 // New intializes a new Parser struct
-func New(consumer Consumer, topic string, schemas string, decoder Decoder, log *logrus.Logger) (*Parser, error) {
+func New(consumer Consumer, topic string, schemas string, decoder Decoder, log *logrus.Logger, opts ...Option) (*Parser, error) {
+	return NewWithRecordDecoder(consumer, topic, schemas, FromDecoder(decoder), log, opts...)
+}
+
+// NewWithRecordDecoder intializes a new Parser struct
+// using decoder, which is passed each message's record
+func NewWithRecordDecoder(consumer Consumer, topic string, schemas string, decoder RecordDecoder, log *logrus.Logger, opts ...Option) (*Parser, error) {
 	err := decoder.ValidateSchemas(schemas)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Parser{
-		consumer: consumer,
-		decoder:  decoder,
-		topic:    topic,
-		log:      log,
-	}, nil
-}
-
-// Serve calls a kafka consumer loop that will listen for
-// messages, decode them, and print them to the console
-func (p *Parser) Serve() chan struct{} {
-	// This will allow the user to exit the loop with
-	// Ctrl-C and shut down the consumer
-	done := make(chan struct{}, 1)
-
-	go func() {
-		//messageCount := 0
-		for {
-			select {
-			case msg, more := <-p.consumer.Messages():
-				if more {
-					// Initial logging here
-					p.log.Infof("Offset: %d", msg.Offset)
-					p.log.Infof("Headers:")
-					for _, header := range msg.Headers {
-						if header != nil {
-							p.log.Infof("\t%s: %s", string(header.Key), string(header.Value))
-						}
-					}
-
-					// Use the passed decoder to read the message to a map
-					// Only supporting the []byte msg.Value in Decode because
-					// Go plugins have trouble with vendored dependencies
-					data, err := p.decoder.Decode(msg.Value)
-					if err != nil {
-						p.log.Errorf("Error decoding message: %s", err.Error())
-					} else {
-						// Print message as JSON
-						p.printJSON(data)
-					}
-				}
-			case err, more := <-p.consumer.Errors():
-				if more {
-					p.log.Errorf("Error: %s", err.Error())
+	p := &Parser{
+		consumer:  consumer,
+		decoder:   decoder,
+		topic:     topic,
+		log:       log,
+		sink:      WriterSink(os.Stdout),
+		formatter: indentedJSON{},
+		onError:   ErrorPolicySkip,
+		finished:  make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.keyDecoder != nil {
+		err = p.keyDecoder.ValidateSchemas(p.keySchemas)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// WithLimits makes the Parser stop on its own once
+// the limits are reached
+func WithLimits(limits Limits) Option {
+	return func(p *Parser) {
+		p.limits = limits
+	}
+}
+
+// WithOutput sets where decoded messages are written,
+// defaults to os.Stdout. Diagnostics always go to the logger.
+func WithOutput(out io.Writer) Option {
+	return func(p *Parser) {
+		p.sink = WriterSink(out)
+	}
+}
+
+// WithSink writes decoded messages to sink, which
+// is closed by Close
+func WithSink(sink Sink) Option {
+	return func(p *Parser) {
+		p.sink = sink
+	}
+}
+
+// WithKeyDecoder decodes message keys with decoder,
+// its schemas are validated by New. Without it keys
+// are printed as strings.
+func WithKeyDecoder(decoder Decoder, schemas string) Option {
+	return WithKeyRecordDecoder(FromDecoder(decoder), schemas)
+}
+
+// WithKeyRecordDecoder is WithKeyDecoder for a RecordDecoder.
+// The decoder is passed a copy of the record with Value set
+// to the key, so value decoders work unchanged.
+func WithKeyRecordDecoder(decoder RecordDecoder, schemas string) Option {
+	return func(p *Parser) {
+		p.keyDecoder = decoder
+		p.keySchemas = schemas
+	}
+}
+
+// WithFormatter sets how envelopes are written to the
+// output, defaults to indented JSON
+func WithFormatter(formatter Formatter) Option {
+	return func(p *Parser) {
+		p.formatter = formatter
+	}
+}
+
+// WithConverters runs converters on every decoded value,
+// in the order they're passed. Values that aren't objects
+// are passed through unchanged.
+func WithConverters(converters ...Converter) Option {
+	return func(p *Parser) {
+		p.converters = append(p.converters, converters...)
+	}
+}
+
+// WithTombstones sets whether records with a nil value,
+// deletes on compacted topics, are printed. They're shown
+// by default, with a nil value and Tombstone set, since
+// they can't be decoded.
+func WithTombstones(show bool) Option {
+	return func(p *Parser) {
+		p.hideTombstones = !show
+	}
+}
+
+// WithErrorPolicy sets what happens to records that
+// fail to decode, see ErrorPolicy. Errors are always
+// logged.
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(p *Parser) {
+		p.onError = policy
+	}
+}
+
+// WithDeadLetters writes every record that fails to
+// decode to out as a DeadLetter, whatever the error policy
+func WithDeadLetters(out io.Writer) Option {
+	return func(p *Parser) {
+		p.deadLetters = out
+	}
+}
+
+// WithSummary writes a Summary to out once the
+// Parser has been stopped
+func WithSummary(out io.Writer) Option {
+	return func(p *Parser) {
+		p.summaryOut = out
+	}
+}
+
+// Err returns the error that stopped the Parser when
+// the error policy is ErrorPolicyStop, which Run also
+// returns. It's only set once Finished is closed.
+func (p *Parser) Err() error {
+	return p.err
+}
+
+// Finished is closed once Run has returned
+func (p *Parser) Finished() <-chan struct{} {
+	return p.finished
+}
+
+// Run consumes messages, decodes them and prints them until
+// the consumer closes its channels, the Parser's limits are
+// reached or ctx is cancelled, in which case ctx.Err() is
+// returned. Records that fail to decode return an error when
+// the error policy is ErrorPolicyStop. Run doesn't close the
+// consumer, call Close once it returns. Run can only be
+// called once.
+func (p *Parser) Run(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&p.running, 0, 1) {
+		return ErrAlreadyRunning
+	}
+	defer close(p.finished)
+
+	p.summary.Messages = make(map[int32]int)
+	start := time.Now()
+	defer func() {
+		p.summary.Elapsed = time.Since(start)
+	}()
+
+	// Copy the end offsets since completed
+	// partitions are removed as we go
+	var remaining map[int32]int64
+	done := make(map[int32]bool)
+	if p.limits.EndOffsets != nil {
+		remaining = make(map[int32]int64, len(p.limits.EndOffsets))
+		for partition, end := range p.limits.EndOffsets {
+			remaining[partition] = end
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+	}
+
+	// Partitions the consumer has fetched up to their
+	// end are checked every so often, in case the messages
+	// left below the end are never delivered
+	var settle <-chan time.Time
+	delivered := start
+	marker, ok := p.consumer.(highWaterMarker)
+	if remaining != nil && ok {
+		ticker := time.NewTicker(p.settleAfter() / 4)
+		defer ticker.Stop()
+		settle = ticker.C
+	}
+
+	// Closed channels are set to nil so they're
+	// never selected again, nil channels are never
+	// ready so consumers may return nil for any of them
+	messages := p.consumer.Messages()
+	errs := p.consumer.Errors()
+	notifications := p.consumer.Notifications()
+
+	for messages != nil || errs != nil || notifications != nil {
+		select {
+		case msg, more := <-messages:
+			if !more {
+				messages = nil
+				continue
+			}
+
+			delivered = time.Now()
+			if remaining != nil && !p.withinLimits(msg, remaining, done) {
+				if len(remaining) == 0 {
+					return nil
 				}
-			case notification, more := <-p.consumer.Notifications():
-				if more {
-					p.log.Warnf("Rebalanced: %+v", notification)
+				continue
+			}
+
+			var err error
+			record := newRecord(msg)
+			if record.Value == nil {
+				err = p.processTombstone(record)
+			} else {
+				err = p.process(record)
+			}
+			p.summary.Messages[msg.Partition]++
+			p.summary.Bytes += int64(len(msg.Key) + len(msg.Value))
+			if err != nil {
+				p.err = err
+				return err
+			}
+			p.markOffset(msg)
+
+			// Printing can block for longer than Settle,
+			// the consumer is only idle once it's done
+			delivered = time.Now()
+
+			if p.limits.MaxMessages > 0 && p.printed >= p.limits.MaxMessages {
+				return nil
+			}
+			if remaining != nil && len(remaining) == 0 {
+				return nil
+			}
+		case err, more := <-errs:
+			if !more {
+				errs = nil
+				continue
+			}
+			p.log.Errorf("Error: %s", err.Error())
+		case notification, more := <-notifications:
+			if !more {
+				notifications = nil
+				continue
+			}
+			p.log.Warnf("Rebalanced: %+v", notification)
+
+			// Only the partitions assigned to
+			// this member of the group are waited on
+			if remaining != nil && notification.Type == cluster.RebalanceOK {
+				p.assigned(notification.Current[p.topic], remaining, done)
+				if len(remaining) == 0 {
+					return nil
 				}
-			case <-done:
-				// TODO figure out why log isn't done fast enough
-				//p.log.Infof("Processed a total of %d messages.", messageCount)
-				return
 			}
+		case <-settle:
+			if time.Since(delivered) < p.settleAfter() {
+				continue
+			}
+			p.settled(marker.HighWaterMarks()[p.topic], remaining, done)
+			if len(remaining) == 0 {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
 		}
-	}()
+	}
+
+	return nil
+}
+
+// Serve runs the Parser in the background, call
+// Stop on the returned Handle to shut it down
+func (p *Parser) Serve() *Handle {
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.Run(ctx)
 
-	return done
+	return &Handle{
+		parser: p,
+		cancel: cancel,
+	}
 }
 
-func (p *Parser) printJSON(data interface{}) {
-	marshalled, err := json.MarshalIndent(data, "", "    ")
+// Close commits offsets when consuming as part of a group
+// and closes the consumer, so the group is left cleanly,
+// then closes the sink. The summary is written afterwards
+// if WithSummary was passed. Close must only be called once Run has returned
+// or was never called. Calling it again returns the same
+// error.
+func (p *Parser) Close() error {
+	p.closeOnce.Do(func() {
+		if committer, ok := p.consumer.(offsetCommitter); ok {
+			p.closeErr = committer.CommitOffsets()
+		}
+
+		if err := p.consumer.Close(); err != nil && p.closeErr == nil {
+			p.closeErr = err
+		}
+
+		if err := p.sink.Close(); err != nil && p.closeErr == nil {
+			p.closeErr = err
+		}
+
+		if p.summaryOut != nil {
+			p.writeSummary(p.summaryOut)
+		}
+	})
+
+	return p.closeErr
+}
+
+// markOffset marks msg as processed when consuming
+// as part of a group, so its offset is committed
+func (p *Parser) markOffset(msg *sarama.ConsumerMessage) {
+	if marker, ok := p.consumer.(offsetMarker); ok {
+		marker.MarkOffset(msg, "")
+	}
+}
+
+// process decodes a record and prints it, the returned
+// error is set when the error policy stops the Parser
+func (p *Parser) process(record *Record) error {
+	// Use the passed decoder to read the message to a map
+	data, err := p.decoder.DecodeRecord(record)
+	data, violations := unwrapInvalid(data, "")
+	if err != nil {
+		p.log.Errorf("Error decoding message: %s", err.Error())
+		return p.fail(record, errors.Wrap(err, "error decoding message"))
+	}
+
+	if data, err = p.convert(data); err != nil {
+		p.log.Errorf("Error converting message: %s", err.Error())
+		return p.fail(record, errors.Wrap(err, "error converting message"))
+	}
+
+	key, err := p.decodeKey(record)
+	if err != nil {
+		p.log.Errorf("Error decoding key: %s", err.Error())
+		return p.fail(record, errors.Wrap(err, "error decoding key"))
+	}
+	key, keyViolations := unwrapInvalid(key, "key ")
+
+	// Print message using the formatter
+	envelope := newEnvelope(record, data)
+	envelope.Key = key
+	envelope.Violations = append(violations, keyViolations...)
+	p.print(envelope)
+
+	return nil
+}
+
+// processTombstone prints a record with a nil value,
+// which deletes its key on compacted topics, without
+// decoding it
+func (p *Parser) processTombstone(record *Record) error {
+	if p.hideTombstones {
+		return nil
+	}
+
+	key, err := p.decodeKey(record)
+	if err != nil {
+		p.log.Errorf("Error decoding key: %s", err.Error())
+		return p.fail(record, errors.Wrap(err, "error decoding key"))
+	}
+	key, violations := unwrapInvalid(key, "key ")
+
+	envelope := newEnvelope(record, nil)
+	envelope.Key = key
+	envelope.Tombstone = true
+	envelope.Violations = violations
+	p.print(envelope)
+
+	return nil
+}
+
+// fail writes a record that couldn't be processed to the
+// dead letter output and applies the error policy
+func (p *Parser) fail(record *Record, err error) error {
+	if p.deadLetters != nil {
+		p.writeDeadLetter(record, err)
+	}
+
+	p.summary.Errors++
+
+	switch p.onError {
+	case ErrorPolicyStop:
+		return errors.Wrapf(err, "stopped at topic %s partition %d offset %d", record.Topic, record.Partition, record.Offset)
+	case ErrorPolicyEmit:
+		envelope := newEnvelope(record, nil)
+		if record.Key != nil {
+			envelope.Key = string(record.Key)
+		}
+		envelope.Error = err.Error()
+		envelope.Raw = &Raw{
+			Hex:    hex.EncodeToString(record.Value),
+			Base64: base64.StdEncoding.EncodeToString(record.Value),
+		}
+		p.print(envelope)
+	}
+
+	return nil
+}
+
+func (p *Parser) writeDeadLetter(record *Record, cause error) {
+	marshalled, err := json.Marshal(&DeadLetter{
+		Record: *record,
+		Error:  cause.Error(),
+	})
+	if err != nil {
+		p.log.Errorf("Could not process dead letter: %s", err.Error())
+		return
+	}
+
+	if _, err := p.deadLetters.Write(append(marshalled, '\n')); err != nil {
+		p.log.Errorf("Could not write dead letter: %s", err.Error())
+	}
+}
+
+// withinLimits reports whether msg should be processed and
+// moves its partition from remaining to done once it's complete
+func (p *Parser) withinLimits(msg *sarama.ConsumerMessage, remaining map[int32]int64, done map[int32]bool) bool {
+	end, ok := remaining[msg.Partition]
+	if !ok {
+		// Partition is already complete
+		// or isn't assigned to us
+		return false
+	}
+
+	if msg.Offset >= end || (!p.limits.Until.IsZero() && !msg.Timestamp.Before(p.limits.Until)) {
+		delete(remaining, msg.Partition)
+		done[msg.Partition] = true
+		return false
+	}
+
+	if msg.Offset >= end-1 {
+		delete(remaining, msg.Partition)
+		done[msg.Partition] = true
+	}
+
+	return true
+}
+
+// settled completes the partitions whose high water mark has
+// reached their end. Partitions in remaining haven't been
+// consumed up to their end, so it must only be called once
+// no partition has delivered a message for Settle.
+func (p *Parser) settled(marks map[int32]int64, remaining map[int32]int64, done map[int32]bool) {
+	for partition, end := range remaining {
+		if mark, ok := marks[partition]; ok && mark >= end {
+			delete(remaining, partition)
+			done[partition] = true
+		}
+	}
+}
+
+func (p *Parser) settleAfter() time.Duration {
+	if p.limits.Settle > 0 {
+		return p.limits.Settle
+	}
+
+	return DefaultSettle
+}
+
+// assigned rebuilds remaining from the partitions assigned
+// to this member of the group, others are read by other
+// members. Partitions can come back at a later rebalance,
+// only the ones in done are never waited on again.
+func (p *Parser) assigned(partitions []int32, remaining map[int32]int64, done map[int32]bool) {
+	for partition := range remaining {
+		delete(remaining, partition)
+	}
+
+	for _, partition := range partitions {
+		if end, ok := p.limits.EndOffsets[partition]; ok && !done[partition] {
+			remaining[partition] = end
+		}
+	}
+}
+
+// decodeKey uses the key decoder if one was passed,
+// otherwise the key is returned as a string
+func (p *Parser) decodeKey(record *Record) (interface{}, error) {
+	if record.Key == nil {
+		return nil, nil
+	}
+
+	if p.keyDecoder == nil {
+		return string(record.Key), nil
+	}
+
+	keyRecord := *record
+	keyRecord.Value = record.Key
+	return p.keyDecoder.DecodeRecord(&keyRecord)
+}
+
+// convert runs the converters on value. Decoders that
+// don't return a map, like the JSON decoder, are read
+// back from JSON first so converters see plain values.
+func (p *Parser) convert(value interface{}) (interface{}, error) {
+	if len(p.converters) == 0 {
+		return value, nil
+	}
+
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		marshalled, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		var generic interface{}
+		decoder := json.NewDecoder(bytes.NewReader(marshalled))
+		decoder.UseNumber()
+		if err := decoder.Decode(&generic); err != nil {
+			return nil, err
+		}
+
+		record, ok = generic.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+	}
+
+	for _, converter := range p.converters {
+		if err := converter.ConvertFields(record); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
+
+// unwrapInvalid splits an Invalid value from its
+// violations, which are prefixed with prefix
+func unwrapInvalid(value interface{}, prefix string) (interface{}, []string) {
+	invalid, ok := value.(*Invalid)
+	if !ok {
+		return value, nil
+	}
+
+	violations := make([]string, len(invalid.Violations))
+	for i, violation := range invalid.Violations {
+		violations[i] = prefix + violation
+	}
+
+	return invalid.Value, violations
+}
+
+// newRecord copies the parts of a sarama message
+// decoders are interested in
+func newRecord(msg *sarama.ConsumerMessage) *Record {
+	record := &Record{
+		Topic:          msg.Topic,
+		Partition:      msg.Partition,
+		Offset:         msg.Offset,
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Timestamp:      msg.Timestamp,
+		BlockTimestamp: msg.BlockTimestamp,
+	}
+
+	for _, header := range msg.Headers {
+		if header != nil {
+			record.Headers = append(record.Headers, Header{
+				Key:   string(header.Key),
+				Value: header.Value,
+			})
+		}
+	}
+
+	return record
+}
+
+func newEnvelope(record *Record, value interface{}) *Envelope {
+	envelope := &Envelope{
+		Topic:          record.Topic,
+		Partition:      record.Partition,
+		Offset:         record.Offset,
+		Timestamp:      record.Timestamp,
+		BlockTimestamp: record.BlockTimestamp,
+		Value:          value,
+	}
+
+	if len(record.Headers) > 0 {
+		envelope.Headers = make(map[string]string, len(record.Headers))
+		for _, header := range record.Headers {
+			envelope.Headers[header.Key] = string(header.Value)
+		}
+	}
+
+	return envelope
+}
+
+func (p *Parser) print(envelope *Envelope) {
+	formatted, err := p.formatter.Format(envelope)
 	if err != nil {
 		p.log.Errorf("Could not process message: %s", err.Error())
 		return
 	}
 
-	p.log.Infof("Message:\n%s", string(marshalled))
+	out, header, err := p.sink.Writer(envelope)
+	if err != nil {
+		p.log.Errorf("Could not open output: %s", err.Error())
+		return
+	}
+
+	if header {
+		if formatter, ok := p.formatter.(HeaderFormatter); ok {
+			p.printHeader(out, formatter)
+		}
+	}
+
+	if _, err := out.Write(formatted); err != nil {
+		p.log.Errorf("Could not write message: %s", err.Error())
+		return
+	}
+	p.printed++
+}
+
+func (p *Parser) printHeader(out io.Writer, header HeaderFormatter) {
+	formatted, err := header.Header()
+	if err != nil {
+		p.log.Errorf("Could not process header: %s", err.Error())
+		return
+	}
+
+	if _, err := out.Write(formatted); err != nil {
+		p.log.Errorf("Could not write header: %s", err.Error())
+	}
+}
+
+// indentedJSON is the default Formatter, pkg/output
+// has the full set used by the command line
+type indentedJSON struct{}
+
+func (indentedJSON) Format(envelope *Envelope) ([]byte, error) {
+	marshalled, err := json.MarshalIndent(envelope, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(marshalled, '\n'), nil
 }
@@ -1,9 +1,13 @@
 package parser_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,6 +31,21 @@ type (
 		Msgs   chan *sarama.ConsumerMessage
 		Notifs chan *cluster.Notification
 		Errs   chan error
+		Closed int
+	}
+
+	// testGroupConsumer records the offsets it's asked to
+	// mark and commit like a consumer in a group
+	testGroupConsumer struct {
+		testConsumer
+		Marked    []int64
+		Committed []int64
+	}
+
+	// testMarkConsumer reports fixed high water marks
+	testMarkConsumer struct {
+		testConsumer
+		Marks map[int32]int64
 	}
 )
 
@@ -39,10 +58,25 @@ var (
 )
 
 const (
-	testHeaderKey      = "testHeaderKey"
-	testHeaderValue    = "testHeaderValue"
-	testJSONMsgValue   = `{"testMessage": "someJSON", "anotherTest": 1}`
-	loggedJSONValue    = "Message:\n{\n    \"testMessage\": \"someJSON\",\n    \"anotherTest\": 1\n}"
+	testHeaderKey    = "testHeaderKey"
+	testHeaderValue  = "testHeaderValue"
+	testJSONMsgValue = `{"testMessage": "someJSON", "anotherTest": 1}`
+	printedEnvelope  = `{
+    "topic": "topic",
+    "partition": 0,
+    "offset": 0,
+    "key": "testKey",
+    "timestamp": "2018-07-01T14:05:00Z",
+    "blockTimestamp": "0001-01-01T00:00:00Z",
+    "headers": {
+        "testHeaderKey": "testHeaderValue"
+    },
+    "value": {
+        "testMessage": "someJSON",
+        "anotherTest": 1
+    }
+}
+`
 	loggedNotification = "Rebalanced: &{Type:unknown Claimed:map[] Released:map[] Current:map[]}"
 )
 
@@ -59,8 +93,7 @@ func TestNew(t *testing.T) {
 }
 
 func TestServeWithError(t *testing.T) {
-	errs := make(chan error)
-	defer close(errs)
+	errs := make(chan error, 1)
 	consumer := &testConsumer{
 		Errs: errs,
 	}
@@ -74,13 +107,11 @@ func TestServeWithError(t *testing.T) {
 	require.Nil(t, err)
 	require.NotNil(t, parser)
 
-	// Start the serve loop
-	done := parser.Serve()
-
-	// Send error on the errors channel
+	// Send error on the errors channel, Run
+	// returns once it has been closed
 	errs <- ErrTestErrs
-	time.Sleep(time.Duration(1) * time.Second)
-	done <- struct{}{}
+	close(errs)
+	require.Nil(t, parser.Run(context.Background()))
 
 	logs := hook.AllEntries()
 	require.Equal(t, 1, len(logs))
@@ -89,8 +120,7 @@ func TestServeWithError(t *testing.T) {
 }
 
 func TestServeWithNotification(t *testing.T) {
-	notifs := make(chan *cluster.Notification)
-	defer close(notifs)
+	notifs := make(chan *cluster.Notification, 1)
 	consumer := &testConsumer{
 		Notifs: notifs,
 	}
@@ -104,14 +134,11 @@ func TestServeWithNotification(t *testing.T) {
 	require.Nil(t, err)
 	require.NotNil(t, parser)
 
-	// Start the serve loop
-	done := parser.Serve()
-
 	// Send notification on notifications
 	// channel
 	notifs <- &cluster.Notification{}
-	time.Sleep(time.Duration(1) * time.Second)
-	done <- struct{}{}
+	close(notifs)
+	require.Nil(t, parser.Run(context.Background()))
 
 	logs := hook.AllEntries()
 	require.Equal(t, 1, len(logs))
@@ -130,14 +157,15 @@ func TestServeWithGoodMessage(t *testing.T) {
 		shouldDecode:   true,
 	}
 	log, hook := test.NewNullLogger()
+	out := &bytes.Buffer{}
 
-	parser, err := parser.New(consumer, "topic", "schemas", decoder, log)
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithOutput(out))
 
 	require.Nil(t, err)
 	require.NotNil(t, parser)
 
 	// Start the serve loop
-	done := parser.Serve()
+	handle := parser.Serve()
 
 	// Send message on messages channel
 	//
@@ -148,22 +176,17 @@ func TestServeWithGoodMessage(t *testing.T) {
 				Value: []byte(testHeaderValue),
 			},
 		},
-		Offset: 0,
-		Value:  []byte(testJSONMsgValue),
+		Topic:     "topic",
+		Offset:    0,
+		Key:       []byte("testKey"),
+		Timestamp: time.Date(2018, 7, 1, 14, 5, 0, 0, time.UTC),
+		Value:     []byte(testJSONMsgValue),
 	}
-	time.Sleep(time.Duration(1) * time.Second)
-	done <- struct{}{}
+	require.Nil(t, handle.Stop())
+	<-parser.Finished()
 
-	logs := hook.AllEntries()
-	require.Equal(t, 4, len(logs))
-	assert.Equal(t, logrus.InfoLevel, logs[0].Level)
-	assert.Equal(t, "Offset: 0", logs[0].Message)
-	assert.Equal(t, logrus.InfoLevel, logs[1].Level)
-	assert.Equal(t, "Headers:", logs[1].Message)
-	assert.Equal(t, logrus.InfoLevel, logs[2].Level)
-	assert.Equal(t, fmt.Sprintf("\t%s: %s", testHeaderKey, testHeaderValue), logs[2].Message)
-	assert.Equal(t, logrus.InfoLevel, logs[3].Level)
-	assert.Equal(t, loggedJSONValue, logs[3].Message)
+	assert.Empty(t, hook.AllEntries())
+	assert.Equal(t, printedEnvelope, out.String())
 }
 
 func TestServeDecodeFailure(t *testing.T) {
@@ -177,14 +200,15 @@ func TestServeDecodeFailure(t *testing.T) {
 		shouldDecode:   false,
 	}
 	log, hook := test.NewNullLogger()
+	out := &bytes.Buffer{}
 
-	parser, err := parser.New(consumer, "topic", "schemas", decoder, log)
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithOutput(out))
 
 	require.Nil(t, err)
 	require.NotNil(t, parser)
 
 	// Start the serve loop
-	done := parser.Serve()
+	handle := parser.Serve()
 
 	// Send message on messages channel
 	//
@@ -198,19 +222,62 @@ func TestServeDecodeFailure(t *testing.T) {
 		Offset: 0,
 		Value:  []byte(testJSONMsgValue),
 	}
-	time.Sleep(time.Duration(1) * time.Second)
-	done <- struct{}{}
+	require.Nil(t, handle.Stop())
+	<-parser.Finished()
 
 	logs := hook.AllEntries()
-	require.Equal(t, 4, len(logs))
-	assert.Equal(t, logrus.InfoLevel, logs[0].Level)
-	assert.Equal(t, "Offset: 0", logs[0].Message)
-	assert.Equal(t, logrus.InfoLevel, logs[1].Level)
-	assert.Equal(t, "Headers:", logs[1].Message)
-	assert.Equal(t, logrus.InfoLevel, logs[2].Level)
-	assert.Equal(t, fmt.Sprintf("\t%s: %s", testHeaderKey, testHeaderValue), logs[2].Message)
-	assert.Equal(t, logrus.ErrorLevel, logs[3].Level)
-	assert.Equal(t, loggedDecodeFailed, logs[3].Message)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, logrus.ErrorLevel, logs[0].Level)
+	assert.Equal(t, loggedDecodeFailed, logs[0].Message)
+	assert.Empty(t, out.String())
+}
+
+func TestNewKeyDecoderInvalid(t *testing.T) {
+	var consumer *cluster.Consumer
+	decoder := &testDecoder{
+		shouldValidate: true,
+	}
+	keyDecoder := &testDecoder{}
+	log, _ := test.NewNullLogger()
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithKeyDecoder(keyDecoder, "keySchemas"))
+
+	assert.Nil(t, parser)
+	assert.Equal(t, ErrTestValidateSchemas, err)
+}
+
+func TestServeWithKeyDecoder(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, _ := test.NewNullLogger()
+	out := &bytes.Buffer{}
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log,
+		parser.WithOutput(out), parser.WithKeyDecoder(decoder, "keySchemas"), parser.WithLimits(parser.Limits{MaxMessages: 1}))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	parser.Serve()
+
+	msgs <- &sarama.ConsumerMessage{
+		Key:   []byte(`{"id": 1}`),
+		Value: []byte(testJSONMsgValue),
+	}
+	<-parser.Finished()
+
+	var printed struct {
+		Key map[string]interface{}
+	}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &printed))
+	assert.Equal(t, map[string]interface{}{"id": float64(1)}, printed.Key)
 }
 
 func (t *testDecoder) ValidateSchemas(schemas string) error {
@@ -240,3 +307,626 @@ func (t *testConsumer) Notifications() <-chan *cluster.Notification {
 func (t *testConsumer) Errors() <-chan error {
 	return t.Errs
 }
+
+func (t *testConsumer) Close() error {
+	t.Closed++
+	return nil
+}
+
+func (t *testGroupConsumer) MarkOffset(msg *sarama.ConsumerMessage, metadata string) {
+	t.Marked = append(t.Marked, msg.Offset)
+}
+
+func (t *testGroupConsumer) CommitOffsets() error {
+	t.Committed = append(t.Committed, t.Marked...)
+	t.Marked = nil
+	return nil
+}
+
+func (t *testMarkConsumer) HighWaterMarks() map[string]map[int32]int64 {
+	return map[string]map[int32]int64{"topic": t.Marks}
+}
+
+func TestServeStopsAtMaxMessages(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, _ := test.NewNullLogger()
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithOutput(ioutil.Discard), parser.WithLimits(parser.Limits{
+		MaxMessages: 2,
+	}))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	parser.Serve()
+
+	for offset := int64(0); offset < 2; offset++ {
+		msgs <- &sarama.ConsumerMessage{
+			Offset: offset,
+			Value:  []byte(testJSONMsgValue),
+		}
+	}
+
+	select {
+	case <-parser.Finished():
+	case <-time.After(time.Second):
+		t.Fatal("parser did not stop after max messages")
+	}
+}
+
+func TestServeStopsAtEndOffsets(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, _ := test.NewNullLogger()
+	out := &bytes.Buffer{}
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithOutput(out), parser.WithLimits(parser.Limits{
+		EndOffsets: map[int32]int64{0: 2, 1: 1},
+	}))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	parser.Serve()
+
+	// Partition 1 is complete after offset 0,
+	// so offset 1 is skipped
+	for _, msg := range []*sarama.ConsumerMessage{
+		{Partition: 1, Offset: 0, Value: []byte(testJSONMsgValue)},
+		{Partition: 1, Offset: 1, Value: []byte(testJSONMsgValue)},
+		{Partition: 0, Offset: 0, Value: []byte(testJSONMsgValue)},
+		{Partition: 0, Offset: 1, Value: []byte(testJSONMsgValue)},
+	} {
+		msgs <- msg
+	}
+
+	select {
+	case <-parser.Finished():
+	case <-time.After(time.Second):
+		t.Fatal("parser did not stop at end offsets")
+	}
+
+	var printed []string
+	stream := json.NewDecoder(out)
+	for stream.More() {
+		var envelope struct {
+			Partition int32
+			Offset    int64
+		}
+		require.Nil(t, stream.Decode(&envelope))
+		printed = append(printed, fmt.Sprintf("%d:%d", envelope.Partition, envelope.Offset))
+	}
+	assert.Equal(t, []string{"1:0", "0:0", "0:1"}, printed)
+}
+
+func TestServeSettlesAtHighWaterMark(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	// Offset 2 is a transaction marker, it's never
+	// delivered, and partition 1 hasn't been fetched
+	consumer := &testMarkConsumer{
+		testConsumer: testConsumer{Msgs: msgs},
+		Marks:        map[int32]int64{0: 3, 1: 0},
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, _ := test.NewNullLogger()
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithOutput(ioutil.Discard), parser.WithLimits(parser.Limits{
+		EndOffsets: map[int32]int64{0: 3, 1: 1},
+		Settle:     20 * time.Millisecond,
+	}))
+	require.Nil(t, err)
+
+	parser.Serve()
+
+	for offset := int64(0); offset < 2; offset++ {
+		msgs <- &sarama.ConsumerMessage{
+			Offset: offset,
+			Value:  []byte(testJSONMsgValue),
+		}
+	}
+
+	// Partition 1 is still waited on
+	select {
+	case <-parser.Finished():
+		t.Fatal("parser stopped before partition 1 was read")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	msgs <- &sarama.ConsumerMessage{
+		Partition: 1,
+		Offset:    0,
+		Value:     []byte(testJSONMsgValue),
+	}
+
+	select {
+	case <-parser.Finished():
+	case <-time.After(time.Second):
+		t.Fatal("parser did not stop once the high water mark was reached")
+	}
+	assert.Equal(t, 3, parser.Summary().Total())
+}
+
+// slowWriter takes delay to write anything
+type slowWriter struct {
+	delay time.Duration
+}
+
+func (w slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return len(p), nil
+}
+
+func TestServeDoesNotSettleWhilePrinting(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage, 4)
+	defer close(msgs)
+	// Everything has been fetched but
+	// delivery is held up by the output
+	consumer := &testMarkConsumer{
+		testConsumer: testConsumer{Msgs: msgs},
+		Marks:        map[int32]int64{0: 2, 1: 2},
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, _ := test.NewNullLogger()
+
+	settle := 20 * time.Millisecond
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithOutput(slowWriter{delay: 2 * settle}), parser.WithLimits(parser.Limits{
+		EndOffsets: map[int32]int64{0: 2, 1: 2},
+		Settle:     settle,
+	}))
+	require.Nil(t, err)
+
+	for _, partition := range []int32{0, 1} {
+		for offset := int64(0); offset < 2; offset++ {
+			msgs <- &sarama.ConsumerMessage{
+				Partition: partition,
+				Offset:    offset,
+				Value:     []byte(testJSONMsgValue),
+			}
+		}
+	}
+
+	parser.Serve()
+
+	select {
+	case <-parser.Finished():
+	case <-time.After(time.Second):
+		t.Fatal("parser did not stop at end offsets")
+	}
+	assert.Equal(t, 4, parser.Summary().Total())
+}
+
+func TestServeStopsAtEndOfAssignedPartitions(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	notifs := make(chan *cluster.Notification, 1)
+	defer close(notifs)
+	consumer := &testConsumer{
+		Msgs:   msgs,
+		Notifs: notifs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, _ := test.NewNullLogger()
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithOutput(ioutil.Discard), parser.WithLimits(parser.Limits{
+		EndOffsets: map[int32]int64{0: 1, 1: 1},
+	}))
+	require.Nil(t, err)
+
+	parser.Serve()
+
+	// Partition 1 went to another member of the group
+	notifs <- &cluster.Notification{
+		Type:    cluster.RebalanceOK,
+		Current: map[string][]int32{"topic": {0}},
+	}
+	msgs <- &sarama.ConsumerMessage{
+		Offset: 0,
+		Value:  []byte(testJSONMsgValue),
+	}
+
+	select {
+	case <-parser.Finished():
+	case <-time.After(time.Second):
+		t.Fatal("parser waited on a partition it wasn't assigned")
+	}
+}
+
+func TestServeWaitsOnReassignedPartitions(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	notifs := make(chan *cluster.Notification)
+	defer close(notifs)
+	consumer := &testConsumer{
+		Msgs:   msgs,
+		Notifs: notifs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, _ := test.NewNullLogger()
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithOutput(ioutil.Discard), parser.WithLimits(parser.Limits{
+		EndOffsets: map[int32]int64{0: 1, 1: 1},
+	}))
+	require.Nil(t, err)
+
+	parser.Serve()
+
+	// Partition 1 is taken away and given back
+	for _, assigned := range [][]int32{{0}, {0, 1}} {
+		notifs <- &cluster.Notification{
+			Type:    cluster.RebalanceOK,
+			Current: map[string][]int32{"topic": assigned},
+		}
+	}
+	for _, partition := range []int32{1, 0} {
+		msgs <- &sarama.ConsumerMessage{
+			Partition: partition,
+			Offset:    0,
+			Value:     []byte(testJSONMsgValue),
+		}
+	}
+
+	select {
+	case <-parser.Finished():
+	case <-time.After(time.Second):
+		t.Fatal("parser did not stop at end offsets")
+	}
+	assert.Equal(t, 2, parser.Summary().Total())
+}
+
+func TestServeEmptyEndOffsets(t *testing.T) {
+	consumer := &testConsumer{}
+	decoder := &testDecoder{
+		shouldValidate: true,
+	}
+	log, _ := test.NewNullLogger()
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithLimits(parser.Limits{
+		EndOffsets: map[int32]int64{},
+	}))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	parser.Serve()
+
+	select {
+	case <-parser.Finished():
+	case <-time.After(time.Second):
+		t.Fatal("parser did not stop with nothing to read")
+	}
+}
+
+// upperConverter uppercases the testMessage field
+type upperConverter struct{}
+
+func (upperConverter) ConvertFields(record map[string]interface{}) error {
+	record["testMessage"] = strings.ToUpper(record["testMessage"].(string))
+	return nil
+}
+
+func TestServeWithConverters(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, hook := test.NewNullLogger()
+	out := &bytes.Buffer{}
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log, parser.WithOutput(out),
+		parser.WithConverters(upperConverter{}), parser.WithLimits(parser.Limits{MaxMessages: 2}))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	parser.Serve()
+
+	// Objects are converted, anything else is passed through
+	msgs <- &sarama.ConsumerMessage{
+		Value: []byte(testJSONMsgValue),
+	}
+	msgs <- &sarama.ConsumerMessage{
+		Value: []byte(`"not an object"`),
+	}
+	<-parser.Finished()
+
+	assert.Empty(t, hook.AllEntries())
+	decoded := json.NewDecoder(out)
+	var printed struct {
+		Value interface{}
+	}
+	require.Nil(t, decoded.Decode(&printed))
+	assert.Equal(t, map[string]interface{}{"testMessage": "SOMEJSON", "anotherTest": float64(1)}, printed.Value)
+	require.Nil(t, decoded.Decode(&printed))
+	assert.Equal(t, "not an object", printed.Value)
+}
+
+func TestServeErrorPolicyEmit(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+	}
+	log, _ := test.NewNullLogger()
+	out := &bytes.Buffer{}
+	deadLetters := &bytes.Buffer{}
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log,
+		parser.WithOutput(out), parser.WithLimits(parser.Limits{MaxMessages: 1}),
+		parser.WithErrorPolicy(parser.ErrorPolicyEmit), parser.WithDeadLetters(deadLetters))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	parser.Serve()
+
+	msgs <- &sarama.ConsumerMessage{
+		Headers: []*sarama.RecordHeader{
+			&sarama.RecordHeader{
+				Key:   []byte(testHeaderKey),
+				Value: []byte(testHeaderValue),
+			},
+		},
+		Topic:     "topic",
+		Partition: 2,
+		Offset:    9,
+		Key:       []byte("key"),
+		Value:     []byte{0xde, 0xad},
+	}
+	<-parser.Finished()
+	assert.Nil(t, parser.Err())
+
+	var printed map[string]interface{}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &printed))
+	assert.Equal(t, "key", printed["key"])
+	assert.Nil(t, printed["value"])
+	assert.Equal(t, "error decoding message: "+ErrTestDecodeFailed.Error(), printed["error"])
+	assert.Equal(t, map[string]interface{}{"hex": "dead", "base64": "3q0="}, printed["raw"])
+
+	var deadLetter map[string]interface{}
+	require.Nil(t, json.Unmarshal(deadLetters.Bytes(), &deadLetter))
+	assert.Equal(t, "topic", deadLetter["topic"])
+	assert.Equal(t, float64(2), deadLetter["partition"])
+	assert.Equal(t, float64(9), deadLetter["offset"])
+	assert.Equal(t, "a2V5", deadLetter["key"])
+	assert.Equal(t, "3q0=", deadLetter["value"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": testHeaderKey, "value": "dGVzdEhlYWRlclZhbHVl"},
+	}, deadLetter["headers"])
+	assert.Equal(t, "error decoding message: "+ErrTestDecodeFailed.Error(), deadLetter["error"])
+}
+
+func TestServeErrorPolicyStop(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+	}
+	log, hook := test.NewNullLogger()
+	out := &bytes.Buffer{}
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log,
+		parser.WithOutput(out), parser.WithErrorPolicy(parser.ErrorPolicyStop))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	parser.Serve()
+
+	msgs <- &sarama.ConsumerMessage{
+		Topic:  "topic",
+		Offset: 3,
+		Value:  []byte(testJSONMsgValue),
+	}
+	<-parser.Finished()
+
+	require.NotNil(t, parser.Err())
+	assert.Equal(t, "stopped at topic topic partition 0 offset 3: error decoding message: "+ErrTestDecodeFailed.Error(), parser.Err().Error())
+	assert.Equal(t, loggedDecodeFailed, hook.LastEntry().Message)
+	assert.Empty(t, out.String())
+}
+
+func TestHandleStop(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testGroupConsumer{
+		testConsumer: testConsumer{
+			Msgs: msgs,
+		},
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, hook := test.NewNullLogger()
+	summary := &bytes.Buffer{}
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log,
+		parser.WithOutput(ioutil.Discard), parser.WithSummary(summary))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	handle := parser.Serve()
+
+	msgs <- &sarama.ConsumerMessage{Partition: 1, Offset: 4, Key: []byte("k"), Value: []byte(`{"a": 1}`)}
+	msgs <- &sarama.ConsumerMessage{Partition: 0, Offset: 7, Value: []byte(`{}`)}
+	msgs <- &sarama.ConsumerMessage{Partition: 1, Offset: 5, Value: []byte(`[]`)}
+
+	require.Nil(t, handle.Stop())
+	require.Nil(t, handle.Stop())
+
+	assert.Empty(t, hook.AllEntries())
+	assert.Equal(t, 1, consumer.Closed)
+	assert.Empty(t, consumer.Marked)
+	assert.Equal(t, []int64{4, 7, 5}, consumer.Committed)
+
+	result := parser.Summary()
+	assert.Equal(t, map[int32]int{0: 1, 1: 2}, result.Messages)
+	assert.Equal(t, 3, result.Total())
+	assert.Equal(t, 0, result.Errors)
+	assert.Equal(t, int64(13), result.Bytes)
+
+	lines := strings.Split(strings.TrimSpace(summary.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "Processed a total of 3 messages (13 bytes) in "))
+	assert.True(t, strings.HasSuffix(lines[0], ", 0 could not be decoded"))
+	assert.Equal(t, "  partition 0: 1 messages", lines[1])
+	assert.Equal(t, "  partition 1: 2 messages", lines[2])
+}
+
+func TestHandleStopAfterLimits(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, _ := test.NewNullLogger()
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log,
+		parser.WithOutput(ioutil.Discard), parser.WithLimits(parser.Limits{MaxMessages: 1}))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	handle := parser.Serve()
+
+	msgs <- &sarama.ConsumerMessage{Value: []byte(testJSONMsgValue)}
+	<-handle.Finished()
+
+	require.Nil(t, handle.Stop())
+	assert.Equal(t, 1, consumer.Closed)
+	assert.Equal(t, 1, parser.Summary().Total())
+}
+
+func TestRunEndsWhenChannelsClose(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage, 2)
+	errs := make(chan error)
+	notifs := make(chan *cluster.Notification)
+	consumer := &testConsumer{
+		Msgs:   msgs,
+		Errs:   errs,
+		Notifs: notifs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+		shouldDecode:   true,
+	}
+	log, hook := test.NewNullLogger()
+	out := &bytes.Buffer{}
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log,
+		parser.WithOutput(out), parser.WithFormatter(lineFormatter{}))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	msgs <- &sarama.ConsumerMessage{Offset: 1, Value: []byte(`1`)}
+	msgs <- &sarama.ConsumerMessage{Offset: 2, Value: []byte(`2`)}
+	close(msgs)
+	close(errs)
+	close(notifs)
+
+	require.Nil(t, parser.Run(context.Background()))
+	require.Nil(t, parser.Close())
+
+	assert.Empty(t, hook.AllEntries())
+	assert.Equal(t, "1\n2\n", out.String())
+	assert.Equal(t, 1, consumer.Closed)
+	assert.Equal(t, 2, parser.Summary().Total())
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	consumer := &testConsumer{
+		Msgs: make(chan *sarama.ConsumerMessage),
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+	}
+	log, _ := test.NewNullLogger()
+
+	p, err := parser.New(consumer, "topic", "schemas", decoder, log)
+
+	require.Nil(t, err)
+	require.NotNil(t, p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, p.Run(ctx))
+	<-p.Finished()
+
+	assert.Equal(t, parser.ErrAlreadyRunning, p.Run(context.Background()))
+}
+
+func TestRunErrorPolicyStop(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage, 1)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	decoder := &testDecoder{
+		shouldValidate: true,
+	}
+	log, _ := test.NewNullLogger()
+
+	parser, err := parser.New(consumer, "topic", "schemas", decoder, log,
+		parser.WithErrorPolicy(parser.ErrorPolicyStop))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	msgs <- &sarama.ConsumerMessage{Topic: "topic", Partition: 1, Offset: 2, Value: []byte(`{}`)}
+
+	err = parser.Run(context.Background())
+	require.NotNil(t, err)
+	assert.Equal(t, parser.Err(), err)
+	assert.Equal(t, "stopped at topic topic partition 1 offset 2: error decoding message: "+ErrTestDecodeFailed.Error(), err.Error())
+}
+
+// lineFormatter writes each value on its own line
+type lineFormatter struct{}
+
+func (lineFormatter) Format(envelope *parser.Envelope) ([]byte, error) {
+	return []byte(fmt.Sprintf("%s\n", envelope.Value)), nil
+}
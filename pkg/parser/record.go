@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"time"
+)
+
+type (
+	// Record is a Kafka message as passed to a RecordDecoder.
+	// It only uses standard library types so decoder plugins
+	// don't need to share the consumer's Kafka dependencies.
+	Record struct {
+		Topic          string    `json:"topic"`
+		Partition      int32     `json:"partition"`
+		Offset         int64     `json:"offset"`
+		Key            []byte    `json:"key"`
+		Value          []byte    `json:"value"`
+		Headers        []Header  `json:"headers,omitempty"`
+		Timestamp      time.Time `json:"timestamp"`
+		BlockTimestamp time.Time `json:"blockTimestamp"`
+	}
+
+	// Header is a single Kafka record header
+	Header struct {
+		Key   string `json:"key"`
+		Value []byte `json:"value"`
+	}
+
+	// RecordDecoder is the interface used by decoders which
+	// need more than the message value, like headers carrying
+	// a content type or schema version
+	RecordDecoder interface {
+		// ValidateSchemas behaves the same as Decoder.ValidateSchemas
+		ValidateSchemas(schemas string) error
+
+		// DecodeRecord takes in a whole Kafka record and returns
+		// an interface{} which can be read by json.Marshal() and an error
+		DecodeRecord(record *Record) (interface{}, error)
+	}
+
+	// Invalid is returned by decoders for values that were
+	// decoded but break the contract they're checked against,
+	// like a JSON Schema. The Parser prints Value with the
+	// Violations alongside it.
+	Invalid struct {
+		Value      interface{}
+		Violations []string
+	}
+
+	// decoderAdapter lets a Decoder be used as a RecordDecoder
+	decoderAdapter struct {
+		Decoder
+	}
+)
+
+// FromDecoder adapts a Decoder, which is only passed the message
+// value, to the RecordDecoder interface. Decoders which already
+// implement RecordDecoder are returned as is.
+func FromDecoder(decoder Decoder) RecordDecoder {
+	if recordDecoder, ok := decoder.(RecordDecoder); ok {
+		return recordDecoder
+	}
+
+	return &decoderAdapter{decoder}
+}
+
+// DecodeRecord passes the record's value to the Decoder
+func (d *decoderAdapter) DecodeRecord(record *Record) (interface{}, error) {
+	return d.Decode(record.Value)
+}
+
+// HeaderValue returns the value of the last header with
+// the given key and whether one was found
+func (r *Record) HeaderValue(key string) ([]byte, bool) {
+	for i := len(r.Headers) - 1; i >= 0; i-- {
+		if r.Headers[i].Key == key {
+			return r.Headers[i].Value, true
+		}
+	}
+
+	return nil, false
+}
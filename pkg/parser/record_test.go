@@ -0,0 +1,275 @@
+package parser_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/kenschneider18/go-kafka-console-consumer/pkg/parser"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// headerDecoder decodes the record to the value
+// of its content-type header and its offset
+type headerDecoder struct{}
+
+func (headerDecoder) ValidateSchemas(schemas string) error {
+	return nil
+}
+
+func (headerDecoder) Decode(msg []byte) (interface{}, error) {
+	return string(msg), nil
+}
+
+func (headerDecoder) DecodeRecord(record *parser.Record) (interface{}, error) {
+	contentType, _ := record.HeaderValue("content-type")
+	return map[string]interface{}{
+		"contentType": string(contentType),
+		"offset":      record.Offset,
+		"value":       string(record.Value),
+	}, nil
+}
+
+func TestFromDecoder(t *testing.T) {
+	decoder := parser.FromDecoder(&testDecoder{shouldDecode: true})
+
+	decoded, err := decoder.DecodeRecord(&parser.Record{
+		Key:   []byte("key"),
+		Value: []byte(testJSONMsgValue),
+	})
+
+	require.Nil(t, err)
+	assert.Equal(t, json.RawMessage(testJSONMsgValue), decoded)
+}
+
+func TestFromDecoderRecordDecoder(t *testing.T) {
+	decoder := headerDecoder{}
+
+	assert.Equal(t, decoder, parser.FromDecoder(decoder))
+}
+
+func TestHeaderValue(t *testing.T) {
+	record := &parser.Record{
+		Headers: []parser.Header{
+			{Key: "content-type", Value: []byte("text/plain")},
+			{Key: "other", Value: []byte("x")},
+			{Key: "content-type", Value: []byte("application/json")},
+		},
+	}
+
+	value, ok := record.HeaderValue("content-type")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("application/json"), value)
+
+	_, ok = record.HeaderValue("missing")
+	assert.False(t, ok)
+}
+
+func TestServeWithRecordDecoder(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	log, hook := test.NewNullLogger()
+	out := &bytes.Buffer{}
+
+	parser, err := parser.NewWithRecordDecoder(consumer, "topic", "schemas", headerDecoder{}, log,
+		parser.WithOutput(out), parser.WithLimits(parser.Limits{MaxMessages: 1}))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	parser.Serve()
+
+	msgs <- &sarama.ConsumerMessage{
+		Headers: []*sarama.RecordHeader{
+			&sarama.RecordHeader{
+				Key:   []byte("content-type"),
+				Value: []byte("text/plain"),
+			},
+		},
+		Offset: 7,
+		Value:  []byte("hello"),
+	}
+	<-parser.Finished()
+
+	var printed struct {
+		Value map[string]interface{}
+	}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &printed))
+	assert.Empty(t, hook.AllEntries())
+	assert.Equal(t, map[string]interface{}{
+		"contentType": "text/plain",
+		"offset":      float64(7),
+		"value":       "hello",
+	}, printed.Value)
+}
+
+// invalidDecoder reports every value as breaking its contract
+type invalidDecoder struct{}
+
+func (invalidDecoder) ValidateSchemas(schemas string) error {
+	return nil
+}
+
+func (invalidDecoder) Decode(msg []byte) (interface{}, error) {
+	return &parser.Invalid{
+		Value:      string(msg),
+		Violations: []string{"#: " + string(msg) + " is not allowed"},
+	}, nil
+}
+
+func TestServeWithViolations(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	log, hook := test.NewNullLogger()
+	out := &bytes.Buffer{}
+
+	decoder := invalidDecoder{}
+	parser, err := parser.New(consumer, "topic", "", decoder, log,
+		parser.WithOutput(out), parser.WithLimits(parser.Limits{MaxMessages: 1}),
+		parser.WithKeyDecoder(decoder, ""))
+
+	require.Nil(t, err)
+	require.NotNil(t, parser)
+
+	parser.Serve()
+
+	msgs <- &sarama.ConsumerMessage{
+		Key:   []byte("k"),
+		Value: []byte("v"),
+	}
+	<-parser.Finished()
+
+	var printed struct {
+		Key        interface{}
+		Value      interface{}
+		Violations []string
+	}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &printed))
+	assert.Empty(t, hook.AllEntries())
+	assert.Equal(t, "k", printed.Key)
+	assert.Equal(t, "v", printed.Value)
+	assert.Equal(t, []string{"#: v is not allowed", "key #: k is not allowed"}, printed.Violations)
+}
+
+func TestServeTombstones(t *testing.T) {
+	for _, show := range []bool{true, false} {
+		msgs := make(chan *sarama.ConsumerMessage)
+		consumer := &testConsumer{
+			Msgs: msgs,
+		}
+		log, hook := test.NewNullLogger()
+		out := &bytes.Buffer{}
+
+		// Hidden tombstones don't count towards the limit
+		limit := 1
+		if show {
+			limit = 2
+		}
+		parser, err := parser.New(consumer, "topic", "", headerDecoder{}, log,
+			parser.WithOutput(out), parser.WithLimits(parser.Limits{MaxMessages: limit}), parser.WithTombstones(show))
+
+		require.Nil(t, err)
+		require.NotNil(t, parser)
+
+		parser.Serve()
+
+		msgs <- &sarama.ConsumerMessage{
+			Key:    []byte("deleted"),
+			Offset: 1,
+		}
+		msgs <- &sarama.ConsumerMessage{
+			Key:    []byte("kept"),
+			Offset: 2,
+			Value:  []byte{},
+		}
+		<-parser.Finished()
+		close(msgs)
+
+		assert.Empty(t, hook.AllEntries())
+
+		var printed []map[string]interface{}
+		decoder := json.NewDecoder(out)
+		for decoder.More() {
+			var envelope map[string]interface{}
+			require.Nil(t, decoder.Decode(&envelope))
+			printed = append(printed, envelope)
+		}
+
+		if show {
+			require.Len(t, printed, 2)
+
+			tombstone := printed[0]
+			assert.Equal(t, "deleted", tombstone["key"])
+			assert.Equal(t, float64(1), tombstone["offset"])
+			assert.Nil(t, tombstone["value"])
+			assert.Equal(t, true, tombstone["tombstone"])
+		} else {
+			require.Len(t, printed, 1)
+		}
+
+		kept := printed[len(printed)-1]
+		assert.Equal(t, "kept", kept["key"])
+		assert.NotContains(t, kept, "tombstone")
+	}
+}
+
+// failingDecoder fails to decode the value "bad"
+type failingDecoder struct{}
+
+func (failingDecoder) ValidateSchemas(schemas string) error {
+	return nil
+}
+
+func (failingDecoder) Decode(msg []byte) (interface{}, error) {
+	if string(msg) == "bad" {
+		return nil, errors.New("bad value")
+	}
+	return string(msg), nil
+}
+
+func TestServeMaxMessagesCountsPrinted(t *testing.T) {
+	msgs := make(chan *sarama.ConsumerMessage)
+	defer close(msgs)
+	consumer := &testConsumer{
+		Msgs: msgs,
+	}
+	log, _ := test.NewNullLogger()
+	out := &bytes.Buffer{}
+
+	parser, err := parser.New(consumer, "topic", "", failingDecoder{}, log,
+		parser.WithOutput(out), parser.WithLimits(parser.Limits{MaxMessages: 1}), parser.WithTombstones(false))
+	require.Nil(t, err)
+
+	parser.Serve()
+
+	// Neither is printed so the parser keeps going
+	msgs <- &sarama.ConsumerMessage{Offset: 1}
+	msgs <- &sarama.ConsumerMessage{Offset: 2, Value: []byte("bad")}
+	select {
+	case <-parser.Finished():
+		t.Fatal("parser stopped before printing a message")
+	default:
+	}
+
+	msgs <- &sarama.ConsumerMessage{Offset: 3, Value: []byte("good")}
+	<-parser.Finished()
+
+	var printed struct {
+		Offset int64
+		Value  string
+	}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &printed))
+	assert.Equal(t, int64(3), printed.Offset)
+	assert.Equal(t, "good", printed.Value)
+	assert.Equal(t, 3, parser.Summary().Total())
+}
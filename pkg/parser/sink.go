@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"io"
+)
+
+type (
+	// Sink decides where every formatted envelope is
+	// written, pkg/output has sinks writing to files
+	Sink interface {
+		// Writer returns the writer for envelope. header is
+		// true when the writer hasn't been written to yet, like
+		// a file that was just opened, so the header of a
+		// HeaderFormatter is written to it first.
+		Writer(envelope *Envelope) (w io.Writer, header bool, err error)
+
+		// Close is called by Parser.Close and closes
+		// everything the sink opened
+		Close() error
+	}
+
+	// writerSink writes everything to one io.Writer
+	writerSink struct {
+		out     io.Writer
+		started bool
+	}
+)
+
+// WriterSink returns a Sink writing every envelope to
+// out, which isn't closed by the Sink
+func WriterSink(out io.Writer) Sink {
+	return &writerSink{out: out}
+}
+
+func (w *writerSink) Writer(envelope *Envelope) (io.Writer, bool, error) {
+	header := !w.started
+	w.started = true
+	return w.out, header, nil
+}
+
+func (w *writerSink) Close() error {
+	return nil
+}